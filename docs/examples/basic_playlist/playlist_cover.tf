@@ -22,8 +22,6 @@ resource "spotify_playlist_cover" "dynamic_cover" {
     "romantic"  = "#FF33A1",  # Pink for romantic
   }, data.spotify_weather.current.mood, "#1DB954") # Default to Spotify green
 
-  force_update    = true
-  
   # This resource is automatically created with the playlist
   # No separate terraform apply needed
   lifecycle {
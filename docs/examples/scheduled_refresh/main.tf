@@ -345,9 +345,6 @@ resource "spotify_playlist_cover" "dynamic_cover" {
     "romantic"  = "#FF0066",  # Hot pink for romantic
   }, data.spotify_weather.current.mood, "#1DB954") # Default to Spotify green
 
-  # Force update ensures the cover is refreshed even when mood hasn't changed in Terraform state
-  force_update = true
-  
   # This resource is automatically created with the playlist
   lifecycle {
     create_before_destroy = true
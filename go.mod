@@ -6,10 +6,14 @@ toolchain go1.24.2
 
 // Main dependencies with pinned versions
 require (
+	// Terraform value types, to build resource configurations in tests - pinned by the SDK
+	github.com/hashicorp/go-cty v1.4.1-0.20200414143053-d3edf31b6320
 	// Terraform SDK - pin to specific version for stability
 	github.com/hashicorp/terraform-plugin-sdk/v2 v2.29.0
 	// Spotify API client - pin to specific version
 	github.com/zmb3/spotify/v2 v2.4.3
+	// Image scaling and WebP decoding for playlist covers - pin to specific version
+	golang.org/x/image v0.25.0
 	// OAuth2 library - pin to specific version
 	golang.org/x/oauth2 v0.29.0
 )
@@ -21,7 +25,6 @@ require (
 	github.com/golang/protobuf v1.5.4 // indirect
 	github.com/google/go-cmp v0.5.9 // indirect
 	github.com/hashicorp/errwrap v1.0.0 // indirect
-	github.com/hashicorp/go-cty v1.4.1-0.20200414143053-d3edf31b6320 // indirect
 	github.com/hashicorp/go-hclog v1.5.0 // indirect
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
 	github.com/hashicorp/go-plugin v1.5.1 // indirect
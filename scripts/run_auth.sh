@@ -15,8 +15,9 @@ echo "Environment variables loaded successfully."
 cd "$PROJECT_ROOT/spotify_auth_proxy"
 echo "Running Spotify auth script..."
 
-# Run the auth script
-go run spotify-auth.go
+# Run the auth script, writing the credentials to .env.spotify next to .env.
+# Extra arguments (for example -tfvars or -token-file) are passed through.
+go run . -env-file "$PROJECT_ROOT/.env.spotify" "$@"
 
 # Keep the terminal open
 echo ""
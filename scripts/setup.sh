@@ -31,4 +31,4 @@ echo "terraform apply"
 echo ""
 echo "Or get your Spotify tokens if you haven't already:"
 echo "cd spotify_auth_proxy"
-echo "go run spotify-auth.go"
\ No newline at end of file
+echo "go run ."
\ No newline at end of file
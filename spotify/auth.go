@@ -0,0 +1,216 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/credentials"
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/logging"
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/scopes"
+)
+
+// Supported values of the auth_mode provider argument
+const (
+	// authModeRefreshToken exchanges a refresh token using the client secret
+	authModeRefreshToken = "refresh_token"
+	// authModeClientCredentials uses app-only tokens that cannot access user data
+	authModeClientCredentials = "client_credentials"
+	// authModePKCE exchanges a refresh token minted for a public client without a secret
+	authModePKCE = "pkce"
+)
+
+// authConfig holds the provider arguments needed to build a token source
+type authConfig struct {
+	Mode              string
+	ClientID          string
+	ClientSecret      string
+	RedirectURI       string
+	RefreshToken      string
+	TokenFile         string
+	CredentialProcess string
+	AccountsBaseURL   string
+}
+
+// newAuthTokenSource builds the token source for the configured auth mode and
+// token origin: an external credential process, a token file that is kept
+// up to date as tokens rotate, or the static refresh_token argument
+func newAuthTokenSource(ctx context.Context, cfg authConfig) (oauth2.TokenSource, diag.Diagnostics) {
+	missing := func(argument string) diag.Diagnostics {
+		return diag.Diagnostics{diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Missing %s", argument),
+			Detail:   fmt.Sprintf("The %s argument is required when auth_mode is %q.", argument, cfg.Mode),
+		}}
+	}
+
+	if cfg.CredentialProcess != "" {
+		source, err := credentials.NewProcessTokenSource(cfg.CredentialProcess)
+		if err != nil {
+			return nil, diag.FromErr(fmt.Errorf("invalid credential_process: %s", err))
+		}
+		return newRedactingTokenSource(source), nil
+	}
+
+	endpoint := oauthEndpoint(cfg.AccountsBaseURL)
+
+	var refresher func(*oauth2.Token) oauth2.TokenSource
+	switch cfg.Mode {
+	case authModeClientCredentials:
+		if cfg.ClientSecret == "" {
+			return nil, missing("client_secret")
+		}
+		if cfg.TokenFile != "" {
+			return nil, diag.Errorf("token_file cannot be used with auth_mode %q, app-only tokens are not rotated", cfg.Mode)
+		}
+		ccConfig := &clientcredentials.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			TokenURL:     endpoint.TokenURL,
+		}
+		return newRedactingTokenSource(ccConfig.TokenSource(ctx)), nil
+
+	case authModePKCE:
+		// Public clients identify themselves with the client ID in the request body
+		endpoint.AuthStyle = oauth2.AuthStyleInParams
+		oauthConfig := &oauth2.Config{
+			ClientID:    cfg.ClientID,
+			RedirectURL: cfg.RedirectURI,
+			Endpoint:    endpoint,
+		}
+		refresher = func(token *oauth2.Token) oauth2.TokenSource {
+			return oauthConfig.TokenSource(ctx, token)
+		}
+
+	default:
+		if cfg.ClientSecret == "" {
+			return nil, missing("client_secret")
+		}
+		oauthConfig := &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURI,
+			Endpoint:     endpoint,
+		}
+		refresher = func(token *oauth2.Token) oauth2.TokenSource {
+			return oauthConfig.TokenSource(ctx, token)
+		}
+	}
+
+	if cfg.TokenFile != "" {
+		store := credentials.NewFileStore(cfg.TokenFile)
+		if !store.Exists() {
+			if cfg.RefreshToken == "" {
+				return nil, diag.Errorf("token_file %s does not exist; create it with spotify_auth_proxy or set refresh_token to seed it", cfg.TokenFile)
+			}
+			// Seed the file with an already expired token so the first use refreshes it
+			if err := store.Save(&oauth2.Token{RefreshToken: cfg.RefreshToken, Expiry: time.Now()}); err != nil {
+				return nil, diag.FromErr(fmt.Errorf("error creating token_file: %s", err))
+			}
+		}
+		return newRedactingTokenSource(credentials.NewFileTokenSource(store, refresher)), nil
+	}
+
+	if cfg.RefreshToken == "" {
+		return nil, missing("refresh_token")
+	}
+
+	return newRedactingTokenSource(refresher(&oauth2.Token{RefreshToken: cfg.RefreshToken})), nil
+}
+
+// requireUserAuth returns an error diagnostic when the provider is configured
+// with app-only authentication, which cannot act on behalf of a user
+func requireUserAuth(m interface{}, typeName string) diag.Diagnostics {
+	client, ok := m.(*ProviderClient)
+	if !ok || client.AuthMode != authModeClientCredentials {
+		return nil
+	}
+
+	return diag.Diagnostics{diag.Diagnostic{
+		Severity: diag.Error,
+		Summary:  fmt.Sprintf("%s requires user authentication", typeName),
+		Detail: fmt.Sprintf("%s reads or modifies data of a Spotify user, but the provider is configured with "+
+			"auth_mode = %q, which only grants app-level access. Configure the provider with auth_mode = %q or %q "+
+			"and a refresh_token to use it.", typeName, authModeClientCredentials, authModeRefreshToken, authModePKCE),
+	}}
+}
+
+// missingScopes returns the scopes typeName needs that the token was not
+// granted, or nil when the granted scopes are unknown
+func (c *ProviderClient) missingScopes(typeName string) []string {
+	if c == nil || c.GrantedScopes == nil {
+		return nil
+	}
+	return scopes.Missing(c.GrantedScopes, scopes.Required[typeName])
+}
+
+// missingScopesError describes the scopes a resource or data source is missing
+func missingScopesError(typeName string, missing []string) error {
+	return fmt.Errorf("%s requires the Spotify scope(s) %s, which the configured token was not granted. "+
+		"Re-authorize with spotify_auth_proxy, including these scopes in SPOTIFY_SCOPES, and update the provider credentials",
+		typeName, strings.Join(missing, ", "))
+}
+
+// requireScopes returns an error diagnostic when the token lacks a scope needed by typeName
+func requireScopes(m interface{}, typeName string) diag.Diagnostics {
+	client, _ := m.(*ProviderClient)
+	if missing := client.missingScopes(typeName); len(missing) > 0 {
+		return diag.FromErr(missingScopesError(typeName, missing))
+	}
+	return nil
+}
+
+// customizeDiffRequireScopes fails the plan when the token lacks a scope needed by typeName
+func customizeDiffRequireScopes(typeName string) schema.CustomizeDiffFunc {
+	return func(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+		client, _ := m.(*ProviderClient)
+		if missing := client.missingScopes(typeName); len(missing) > 0 {
+			return missingScopesError(typeName, missing)
+		}
+		return nil
+	}
+}
+
+// scopeWarnings returns one warning per scope that is needed by some resource
+// or data source but was not granted to the token
+func scopeWarnings(granted []string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, scope := range scopes.Missing(granted, scopes.All()) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Spotify token is missing the %s scope", scope),
+			Detail: fmt.Sprintf("The configured token was not granted the %s scope, which is needed by %s. "+
+				"Using them will fail until you re-authorize with this scope.", scope, strings.Join(scopes.RequiredBy(scope), ", ")),
+		})
+	}
+	return diags
+}
+
+// redactingTokenSource registers every token it hands out with the log
+// redaction layer, so access and refresh tokens never reach the logs
+type redactingTokenSource struct {
+	source oauth2.TokenSource
+}
+
+// newRedactingTokenSource wraps the given source; the result is safe for concurrent use
+func newRedactingTokenSource(source oauth2.TokenSource) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, &redactingTokenSource{source: source})
+}
+
+// Token implements oauth2.TokenSource
+func (s *redactingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.source.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	logging.RegisterSecret(token.AccessToken)
+	logging.RegisterSecret(token.RefreshToken)
+	return token, nil
+}
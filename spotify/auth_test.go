@@ -0,0 +1,165 @@
+package spotify
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/credentials"
+)
+
+func TestNewAuthTokenSourceModes(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  authConfig
+		// form holds the expected values of the token request, none is expected when nil
+		form map[string]string
+		// basic is the client ID expected in HTTP basic auth, "" for none
+		basic string
+	}{
+		{
+			name:  "refresh_token",
+			cfg:   authConfig{Mode: authModeRefreshToken, ClientID: "client", ClientSecret: "secret", RefreshToken: "refresh"},
+			form:  map[string]string{"grant_type": "refresh_token", "refresh_token": "refresh"},
+			basic: "client",
+		},
+		{
+			name:  "client_credentials",
+			cfg:   authConfig{Mode: authModeClientCredentials, ClientID: "client", ClientSecret: "secret"},
+			form:  map[string]string{"grant_type": "client_credentials"},
+			basic: "client",
+		},
+		{
+			name: "pkce",
+			cfg:  authConfig{Mode: authModePKCE, ClientID: "client", RefreshToken: "refresh"},
+			form: map[string]string{"grant_type": "refresh_token", "refresh_token": "refresh", "client_id": "client"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSpotifyServer(t)
+			tt.cfg.AccountsBaseURL = server.URL
+
+			source, diags := newAuthTokenSource(context.Background(), tt.cfg)
+			if diags.HasError() {
+				t.Fatalf("Expected a token source, got %v", diags)
+			}
+			token, err := source.Token()
+			if err != nil {
+				t.Fatalf("Expected a token, got %v", err)
+			}
+			if token.AccessToken != fakeAccessToken {
+				t.Errorf("Expected the token from the accounts service, got %q", token.AccessToken)
+			}
+
+			tokenRequests, _ := server.requests()
+			if len(tokenRequests) != 1 {
+				t.Fatalf("Expected one token request, got %d", len(tokenRequests))
+			}
+			for key, value := range tt.form {
+				if got := tokenRequests[0].Get(key); got != value {
+					t.Errorf("Expected %s=%q in the token request, got %q", key, value, got)
+				}
+			}
+			if got := tokenRequests[0].Get("basic_client_id"); got != tt.basic {
+				t.Errorf("Expected basic auth client ID %q, got %q", tt.basic, got)
+			}
+		})
+	}
+}
+
+func TestNewAuthTokenSourceTokenFile(t *testing.T) {
+	server := newFakeSpotifyServer(t)
+	path := filepath.Join(t.TempDir(), "token.json")
+
+	store := credentials.NewFileStore(path)
+	if err := store.Save(&oauth2.Token{RefreshToken: "stored-refresh", Expiry: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatalf("Error saving token file: %v", err)
+	}
+
+	source, diags := newAuthTokenSource(context.Background(), authConfig{
+		Mode:            authModeRefreshToken,
+		ClientID:        "client",
+		ClientSecret:    "secret",
+		RefreshToken:    "configured-refresh",
+		TokenFile:       path,
+		AccountsBaseURL: server.URL,
+	})
+	if diags.HasError() {
+		t.Fatalf("Expected a token source, got %v", diags)
+	}
+	if _, err := source.Token(); err != nil {
+		t.Fatalf("Expected a token, got %v", err)
+	}
+
+	// The stored refresh token takes precedence and the new token is written back
+	tokenRequests, _ := server.requests()
+	if len(tokenRequests) != 1 || tokenRequests[0].Get("refresh_token") != "stored-refresh" {
+		t.Errorf("Expected the stored refresh token to be used, got %v", tokenRequests)
+	}
+	saved, err := store.Load()
+	if err != nil {
+		t.Fatalf("Error loading token file: %v", err)
+	}
+	if saved.AccessToken != fakeAccessToken || saved.RefreshToken != "stored-refresh" {
+		t.Errorf("Expected the refreshed token to be saved, got %+v", saved)
+	}
+}
+
+func TestNewAuthTokenSourceCredentialProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("echo is a shell builtin on Windows")
+	}
+	server := newFakeSpotifyServer(t)
+
+	// The process takes precedence over the auth mode and its arguments
+	source, diags := newAuthTokenSource(context.Background(), authConfig{
+		Mode:              authModeRefreshToken,
+		ClientID:          "client",
+		CredentialProcess: `echo '{"access_token":"process-token","expires_in":3600}'`,
+		AccountsBaseURL:   server.URL,
+	})
+	if diags.HasError() {
+		t.Fatalf("Expected a token source, got %v", diags)
+	}
+	token, err := source.Token()
+	if err != nil {
+		t.Fatalf("Expected a token, got %v", err)
+	}
+	if token.AccessToken != "process-token" {
+		t.Errorf("Expected the token printed by the process, got %q", token.AccessToken)
+	}
+	if tokenRequests, _ := server.requests(); len(tokenRequests) != 0 {
+		t.Errorf("Expected no token requests, got %v", tokenRequests)
+	}
+}
+
+func TestNewAuthTokenSourceRejectsIncompleteConfig(t *testing.T) {
+	missingFile := filepath.Join(t.TempDir(), "missing.json")
+
+	tests := []struct {
+		name string
+		cfg  authConfig
+	}{
+		{"refresh_token without client_secret", authConfig{Mode: authModeRefreshToken, ClientID: "client", RefreshToken: "refresh"}},
+		{"refresh_token without refresh_token", authConfig{Mode: authModeRefreshToken, ClientID: "client", ClientSecret: "secret"}},
+		{"client_credentials without client_secret", authConfig{Mode: authModeClientCredentials, ClientID: "client"}},
+		{"client_credentials with token_file", authConfig{Mode: authModeClientCredentials, ClientID: "client", ClientSecret: "secret", TokenFile: missingFile}},
+		{"pkce without refresh_token", authConfig{Mode: authModePKCE, ClientID: "client"}},
+		{"missing token_file without refresh_token", authConfig{Mode: authModePKCE, ClientID: "client", TokenFile: missingFile}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.AccountsBaseURL = "http://127.0.0.1:0"
+			if _, diags := newAuthTokenSource(context.Background(), tt.cfg); !diags.HasError() {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package coverimage
+
+import (
+	"embed"
+	"fmt"
+	"image"
+	"image/png"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// emojiFiles holds Twemoji glyphs for the emojis the provider picks for moods
+// and weather conditions, see emoji/LICENSE
+//
+//go:embed emoji/*.png
+var emojiFiles embed.FS
+
+// Layouts of the emoji on a generated cover
+const (
+	EmojiCenter = "center"
+	EmojiTiled  = "tiled"
+	EmojiNone   = "none"
+)
+
+// DefaultEmojiSize is the default edge length of the emoji on a 300x300 cover
+const DefaultEmojiSize = 120
+
+// Emoji returns the embedded glyph of an emoji, or false if the emoji is not
+// embedded
+func Emoji(emoji string) (image.Image, bool) {
+	file, err := emojiFiles.Open("emoji/" + emojiFilename(emoji) + ".png")
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	glyph, err := png.Decode(file)
+	if err != nil {
+		return nil, false
+	}
+	return glyph, true
+}
+
+// emojiFilename names a glyph the way Twemoji does: the hexadecimal code
+// points joined by dashes, leaving out the U+FE0F variation selector unless
+// the emoji is a sequence joined with U+200D
+func emojiFilename(emoji string) string {
+	zwj := strings.ContainsRune(emoji, '\u200d')
+
+	var codePoints []string
+	for _, r := range strings.TrimSpace(emoji) {
+		if r == '\ufe0f' && !zwj {
+			continue
+		}
+		codePoints = append(codePoints, fmt.Sprintf("%x", r))
+	}
+	return strings.Join(codePoints, "-")
+}
+
+// DrawEmoji composites glyph onto img, scaled to size x size: once in the
+// center, or tiled in staggered rows across the whole image
+func DrawEmoji(img draw.Image, glyph image.Image, layout string, size int) {
+	bounds := img.Bounds()
+
+	switch layout {
+	case EmojiCenter:
+		center := image.Pt(bounds.Min.X+bounds.Dx()/2, bounds.Min.Y+bounds.Dy()/2)
+		drawGlyph(img, glyph, image.Rect(center.X-size/2, center.Y-size/2, center.X-size/2+size, center.Y-size/2+size))
+
+	case EmojiTiled:
+		step := size * 3 / 2
+		for row, y := 0, bounds.Min.Y-size/2; y < bounds.Max.Y; row, y = row+1, y+step {
+			// Every other row is shifted by half a step
+			x := bounds.Min.X - size/2
+			if row%2 == 1 {
+				x += step / 2
+			}
+			for ; x < bounds.Max.X; x += step {
+				drawGlyph(img, glyph, image.Rect(x, y, x+size, y+size))
+			}
+		}
+	}
+}
+
+// drawGlyph scales glyph into r, which may extend beyond img
+func drawGlyph(img draw.Image, glyph image.Image, r image.Rectangle) {
+	draw.CatmullRom.Scale(img, r, glyph, glyph.Bounds(), draw.Over, nil)
+}
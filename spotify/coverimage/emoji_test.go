@@ -0,0 +1,77 @@
+package coverimage
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// emojis the provider picks for moods and weather conditions
+var providerEmojis = []string{
+	"⚡", "😌", "🧸", "😢", "🥳", "🧠", "💪", "❤️", "😊", "😔", "😡", "🤩", "🎵",
+	"☀️", "☁️", "🌧️", "❄️", "⛈️", "🌫️", "🌬️", "🔥", "🧊", "🌈", "🌤️",
+}
+
+func TestEmojiEmbedded(t *testing.T) {
+	for _, emoji := range providerEmojis {
+		glyph, ok := Emoji(emoji)
+		if !ok {
+			t.Errorf("Emoji %q (%s) is not embedded", emoji, emojiFilename(emoji))
+			continue
+		}
+		if glyph.Bounds().Dx() == 0 || glyph.Bounds().Dy() == 0 {
+			t.Errorf("Emoji %q has an empty glyph", emoji)
+		}
+	}
+
+	if _, ok := Emoji("🎸"); ok {
+		t.Error("Expected 🎸 not to be embedded")
+	}
+}
+
+func TestEmojiFilename(t *testing.T) {
+	tests := map[string]string{
+		"⚡":                          "26a1",
+		"❤️":                         "2764",
+		"🌧️":                         "1f327",
+		"\U0001f469\u200d\U0001f4bb": "1f469-200d-1f4bb",
+	}
+
+	for emoji, expected := range tests {
+		if got := emojiFilename(emoji); got != expected {
+			t.Errorf("emojiFilename(%q) = %q, expected %q", emoji, got, expected)
+		}
+	}
+}
+
+func TestDrawEmoji(t *testing.T) {
+	glyph, ok := Emoji("🔥")
+	if !ok {
+		t.Fatal("Expected 🔥 to be embedded")
+	}
+
+	changed := func(img *image.RGBA, x, y int) bool {
+		return img.At(x, y) != color.RGBA{0, 0, 0, 255}
+	}
+
+	centered := filled(color.Black)
+	DrawEmoji(centered, glyph, EmojiCenter, 120)
+	if !changed(centered, 150, 150) {
+		t.Error("Expected the centered emoji to cover the center")
+	}
+	if changed(centered, 5, 5) || changed(centered, 295, 295) {
+		t.Error("Expected the centered emoji to leave the corners alone")
+	}
+
+	tiled := filled(color.Black)
+	DrawEmoji(tiled, glyph, EmojiTiled, 60)
+	if ink := inkBounds(tiled, color.Black); ink.Dx() < 280 || ink.Dy() < 280 {
+		t.Errorf("Expected tiled emojis to spread over the cover, got %v", ink)
+	}
+
+	untouched := filled(color.Black)
+	DrawEmoji(untouched, glyph, EmojiNone, 120)
+	if ink := inkBounds(untouched, color.Black); !ink.Empty() {
+		t.Errorf("Expected nothing to be drawn, got %v", ink)
+	}
+}
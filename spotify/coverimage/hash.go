@@ -0,0 +1,91 @@
+// Package coverimage processes playlist cover images. Hashes compare covers
+// by what they look like rather than by their bytes, because Spotify
+// re-encodes and resizes every cover it receives.
+package coverimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math/bits"
+	"strconv"
+)
+
+// hashWidth and hashHeight are the size of the grid a hash is computed on.
+// Each row has one more cell than bits, so every bit compares two neighbours.
+const (
+	hashWidth  = 9
+	hashHeight = 8
+)
+
+// DriftThreshold is the number of differing bits above which two hashes
+// belong to different images. Re-encoding and resizing the same image
+// changes only a few bits.
+const DriftThreshold = 10
+
+// Hash returns the difference hash of img: it is shrunk to a 9x8 grayscale
+// grid, and each of the 64 bits is set when a cell is brighter than the cell
+// to its right.
+func Hash(img image.Image) uint64 {
+	grid := shrink(img)
+
+	var hash uint64
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth-1; x++ {
+			hash <<= 1
+			if grid[y][x] > grid[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Distance returns the number of bits that differ between two hashes
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// FormatHash returns the hash as 16 hexadecimal digits
+func FormatHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// ParseHash parses a hash formatted by FormatHash
+func ParseHash(s string) (uint64, error) {
+	if len(s) != 16 {
+		return 0, fmt.Errorf("invalid image hash %q", s)
+	}
+	hash, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid image hash %q", s)
+	}
+	return hash, nil
+}
+
+// shrink averages the luminance of img over a hashWidth x hashHeight grid
+func shrink(img image.Image) [hashHeight][hashWidth]float64 {
+	var sums [hashHeight][hashWidth]float64
+	var counts [hashHeight][hashWidth]int
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	for y := 0; y < height; y++ {
+		cy := y * hashHeight / height
+		for x := 0; x < width; x++ {
+			cx := x * hashWidth / width
+			gray := color.Gray16Model.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray16)
+			sums[cy][cx] += float64(gray.Y)
+			counts[cy][cx]++
+		}
+	}
+
+	for y := range sums {
+		for x := range sums[y] {
+			if counts[y][x] > 0 {
+				sums[y][x] /= float64(counts[y][x])
+			}
+		}
+	}
+	return sums
+}
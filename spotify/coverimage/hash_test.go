@@ -0,0 +1,74 @@
+package coverimage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// gradient returns a size x size image that gets brighter to the right, or
+// to the left when reversed is set
+func gradient(size int, reversed bool) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			v := uint8(x * 255 / size)
+			if reversed {
+				v = 255 - v
+			}
+			img.Set(x, y, color.RGBA{v, uint8(y * 255 / size), 128, 255})
+		}
+	}
+	return img
+}
+
+// reencode encodes img as a JPEG of the given quality and decodes it again
+func reencode(t *testing.T, img image.Image, quality int) image.Image {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatalf("Error encoding JPEG: %v", err)
+	}
+	decoded, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Error decoding JPEG: %v", err)
+	}
+	return decoded
+}
+
+func TestHashSurvivesReencoding(t *testing.T) {
+	original := Hash(gradient(300, false))
+
+	for _, img := range []image.Image{reencode(t, gradient(300, false), 40), gradient(640, false), gradient(60, false)} {
+		if distance := Distance(original, Hash(img)); distance > DriftThreshold {
+			t.Errorf("Expected a distance of at most %d for the same image, got %d", DriftThreshold, distance)
+		}
+	}
+}
+
+func TestHashDetectsDifferentImages(t *testing.T) {
+	if distance := Distance(Hash(gradient(300, false)), Hash(gradient(300, true))); distance <= DriftThreshold {
+		t.Errorf("Expected a distance above %d for different images, got %d", DriftThreshold, distance)
+	}
+}
+
+func TestFormatAndParseHash(t *testing.T) {
+	hash := Hash(gradient(100, true))
+
+	parsed, err := ParseHash(FormatHash(hash))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if parsed != hash {
+		t.Errorf("Expected %x, got %x", hash, parsed)
+	}
+
+	for _, input := range []string{"", "abc", "zzzzzzzzzzzzzzzz"} {
+		if _, err := ParseHash(input); err == nil {
+			t.Errorf("ParseHash(%q) expected an error", input)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package coverimage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+
+	// Decoders for the formats accepted as cover sources
+	_ "image/gif"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxEncodedSize is the largest base64 encoded cover Spotify accepts
+const MaxEncodedSize = 256 * 1024
+
+// Bounds and default of the edge length covers are scaled to
+const (
+	DefaultSize = 640
+	MinSize     = 64
+	MaxSize     = 2000
+)
+
+// CanvasSize is the edge length the sizes of generated cover elements refer
+// to. Generated covers are drawn at the size they are uploaded at, with those
+// sizes scaled to match.
+const CanvasSize = 300
+
+// MaxSourcePixels bounds the width times height of source images, which are
+// decoded into memory in full before they are scaled
+const MaxSourcePixels = 50 * 1000 * 1000
+
+// JPEG qualities tried, from the highest down, until a cover fits MaxEncodedSize
+const (
+	maxQuality  = 90
+	minQuality  = 10
+	qualityStep = 5
+)
+
+// Result is a cover ready to be uploaded
+type Result struct {
+	// Data is the JPEG encoded cover
+	Data []byte
+
+	Width   int
+	Height  int
+	Quality int
+}
+
+// Base64 returns the cover as Spotify expects it in an upload
+func (r *Result) Base64() string {
+	return base64.StdEncoding.EncodeToString(r.Data)
+}
+
+// Prepare decodes a JPEG, PNG, GIF or WebP image and encodes it with Encode.
+// Images larger than MaxSourcePixels are rejected before they are decoded.
+func Prepare(data []byte, size int) (*Result, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image, supported formats are JPEG, PNG, GIF and WebP: %w", err)
+	}
+	if int64(config.Width)*int64(config.Height) > MaxSourcePixels {
+		return nil, fmt.Errorf("%s image is %dx%d, images may have at most %d pixels", format, config.Width, config.Height, MaxSourcePixels)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image, supported formats are JPEG, PNG, GIF and WebP: %w", err)
+	}
+
+	result, err := Encode(img, size)
+	if err != nil {
+		return nil, fmt.Errorf("error converting %s image: %w", format, err)
+	}
+	return result, nil
+}
+
+// Encode crops the center square of img, scales it to size x size and
+// encodes it as a JPEG, at the highest quality whose base64 encoding fits
+// MaxEncodedSize. Transparent areas become white.
+func Encode(img image.Image, size int) (*Result, error) {
+	if size < MinSize || size > MaxSize {
+		return nil, fmt.Errorf("cover size %d is outside of %d to %d", size, MinSize, MaxSize)
+	}
+
+	square := centerSquare(img.Bounds())
+	if square.Empty() {
+		return nil, fmt.Errorf("image is empty")
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(scaled, scaled.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, square, draw.Over, nil)
+
+	var buf bytes.Buffer
+	for quality := maxQuality; quality >= minQuality; quality -= qualityStep {
+		buf.Reset()
+		if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, fmt.Errorf("error encoding JPEG: %w", err)
+		}
+
+		if base64.StdEncoding.EncodedLen(buf.Len()) <= MaxEncodedSize {
+			return &Result{
+				Data:    append([]byte(nil), buf.Bytes()...),
+				Width:   size,
+				Height:  size,
+				Quality: quality,
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("a %dx%d JPEG does not fit in %d bytes of base64 even at quality %d, use a smaller size", size, size, MaxEncodedSize, minQuality)
+}
+
+// centerSquare returns the largest square centered in bounds
+func centerSquare(bounds image.Rectangle) image.Rectangle {
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > height {
+		offset := (width - height) / 2
+		return image.Rect(bounds.Min.X+offset, bounds.Min.Y, bounds.Min.X+offset+height, bounds.Max.Y)
+	}
+	offset := (height - width) / 2
+	return image.Rect(bounds.Min.X, bounds.Min.Y+offset, bounds.Max.X, bounds.Min.Y+offset+width)
+}
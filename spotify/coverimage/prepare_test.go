@@ -0,0 +1,144 @@
+package coverimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// noise returns a width x height image of random pixels, which compresses badly
+func noise(width, height int) *image.RGBA {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rng.Intn(256))
+	}
+	return img
+}
+
+func TestPrepareConvertsFormats(t *testing.T) {
+	src := gradient(200, false)
+
+	encoders := map[string]func(*bytes.Buffer) error{
+		"png":  func(buf *bytes.Buffer) error { return png.Encode(buf, src) },
+		"gif":  func(buf *bytes.Buffer) error { return gif.Encode(buf, src, nil) },
+		"jpeg": func(buf *bytes.Buffer) error { return jpeg.Encode(buf, src, nil) },
+	}
+
+	for format, encode := range encoders {
+		var buf bytes.Buffer
+		if err := encode(&buf); err != nil {
+			t.Fatalf("Error encoding %s: %v", format, err)
+		}
+
+		result, err := Prepare(buf.Bytes(), 100)
+		if err != nil {
+			t.Errorf("Prepare(%s) returned error: %v", format, err)
+			continue
+		}
+
+		decoded, decodedFormat, err := image.Decode(bytes.NewReader(result.Data))
+		if err != nil || decodedFormat != "jpeg" {
+			t.Errorf("Prepare(%s) did not produce a JPEG: %v %s", format, err, decodedFormat)
+			continue
+		}
+		if size := decoded.Bounds().Size(); size != image.Pt(100, 100) || result.Width != 100 || result.Height != 100 {
+			t.Errorf("Prepare(%s) produced %v, reported %dx%d", format, size, result.Width, result.Height)
+		}
+	}
+}
+
+func TestPrepareRejectsInvalidData(t *testing.T) {
+	if _, err := Prepare([]byte("not an image"), DefaultSize); err == nil {
+		t.Error("Expected an error for data that is not an image")
+	}
+}
+
+// pngHeader returns the start of a PNG declaring a width x height RGB image,
+// which is enough for image.DecodeConfig but holds no pixel data
+func pngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8], ihdr[9] = 8, 2
+
+	chunk := append([]byte("IHDR"), ihdr...)
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = binary.BigEndian.AppendUint32(data, uint32(len(ihdr)))
+	data = append(data, chunk...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(chunk))
+}
+
+func TestPrepareRejectsLargeImages(t *testing.T) {
+	_, err := Prepare(pngHeader(20000, 20000), DefaultSize)
+	if err == nil || !strings.Contains(err.Error(), "at most") {
+		t.Errorf("Expected an image over MaxSourcePixels to be rejected before decoding, got %v", err)
+	}
+}
+
+func TestEncodeCropsCenter(t *testing.T) {
+	// A wide image: red sides around a blue center square
+	img := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 300; x++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= 100 && x < 200 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	result, err := Encode(img, MinSize)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	decoded, err := jpeg.Decode(bytes.NewReader(result.Data))
+	if err != nil {
+		t.Fatalf("Error decoding result: %v", err)
+	}
+	for _, p := range []image.Point{{1, 1}, {MinSize - 2, MinSize - 2}, {MinSize / 2, MinSize / 2}} {
+		r, _, b, _ := decoded.At(p.X, p.Y).RGBA()
+		if r > b {
+			t.Errorf("Expected only the blue center at %v, got red %d blue %d", p, r>>8, b>>8)
+		}
+	}
+}
+
+func TestEncodeStepsDownQuality(t *testing.T) {
+	small, err := Encode(gradient(300, false), 300)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if small.Quality != maxQuality {
+		t.Errorf("Expected quality %d for a small image, got %d", maxQuality, small.Quality)
+	}
+
+	large, err := Encode(noise(600, 600), 600)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if large.Quality >= maxQuality {
+		t.Errorf("Expected a quality below %d for a noisy image, got %d", maxQuality, large.Quality)
+	}
+	if len(large.Base64()) > MaxEncodedSize {
+		t.Errorf("Encoded cover of %d bytes exceeds %d", len(large.Base64()), MaxEncodedSize)
+	}
+}
+
+func TestEncodeRejectsInvalidSize(t *testing.T) {
+	for _, size := range []int{0, MinSize - 1, MaxSize + 1} {
+		if _, err := Encode(gradient(100, false), size); err == nil {
+			t.Errorf("Encode with size %d expected an error", size)
+		}
+	}
+}
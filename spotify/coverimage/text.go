@@ -0,0 +1,273 @@
+package coverimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Horizontal alignments of text on a cover
+const (
+	AlignLeft   = "left"
+	AlignCenter = "center"
+	AlignRight  = "right"
+)
+
+// Vertical positions of text on a cover
+const (
+	PositionTop    = "top"
+	PositionCenter = "center"
+	PositionBottom = "bottom"
+)
+
+// Defaults for text drawn on a 300x300 cover
+const (
+	DefaultTitleSize    = 36
+	DefaultSubtitleSize = 18
+	DefaultPadding      = 24
+)
+
+// minFontSize is the size below which text is no longer shrunk to fit
+const minFontSize = 8
+
+// Text is a title and a subtitle drawn onto a cover
+type Text struct {
+	Title    string
+	Subtitle string
+
+	// TitleSize and SubtitleSize are font sizes in pixels
+	TitleSize    float64
+	SubtitleSize float64
+
+	// Align is one of the Align constants, Position one of the Position constants
+	Align    string
+	Position string
+
+	// Color is the text color. When nil, black or white is picked, whichever
+	// contrasts more with the area behind the text.
+	Color color.Color
+
+	// Padding is the margin kept free of text on every side, in pixels
+	Padding int
+}
+
+// fonts are the embedded Go fonts, parsed on first use. The Go fonts are
+// distributed under a BSD license with golang.org/x/image.
+var fonts struct {
+	once    sync.Once
+	bold    *opentype.Font
+	regular *opentype.Font
+	err     error
+}
+
+func loadFonts() (*opentype.Font, *opentype.Font, error) {
+	fonts.once.Do(func() {
+		if fonts.bold, fonts.err = opentype.Parse(gobold.TTF); fonts.err != nil {
+			return
+		}
+		fonts.regular, fonts.err = opentype.Parse(goregular.TTF)
+	})
+	return fonts.bold, fonts.regular, fonts.err
+}
+
+// textLine is a laid out line of text
+type textLine struct {
+	face  font.Face
+	text  string
+	width int
+}
+
+// DrawText draws the title in bold with the subtitle below it. Lines are
+// wrapped at spaces to fit inside the padding, and both font sizes are
+// reduced together until the text fits the area inside the padding.
+func DrawText(img draw.Image, text Text) error {
+	hasTitle, hasSubtitle := strings.TrimSpace(text.Title) != "", strings.TrimSpace(text.Subtitle) != ""
+	if !hasTitle && !hasSubtitle {
+		return nil
+	}
+
+	bold, regular, err := loadFonts()
+	if err != nil {
+		return fmt.Errorf("error loading fonts: %w", err)
+	}
+
+	area := img.Bounds().Inset(text.Padding)
+	if area.Empty() {
+		return fmt.Errorf("padding %d leaves no room for text", text.Padding)
+	}
+
+	var faces []font.Face
+	defer func() {
+		for _, face := range faces {
+			face.Close()
+		}
+	}()
+
+	var lines []textLine
+	var gap int
+	for scale := 1.0; ; scale *= 0.9 {
+		titleSize, subtitleSize := text.TitleSize*scale, text.SubtitleSize*scale
+
+		titleFace, err := opentype.NewFace(bold, &opentype.FaceOptions{Size: titleSize, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return err
+		}
+		faces = append(faces, titleFace)
+
+		subtitleFace, err := opentype.NewFace(regular, &opentype.FaceOptions{Size: subtitleSize, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return err
+		}
+		faces = append(faces, subtitleFace)
+
+		lines = append(wrapText(titleFace, text.Title, area.Dx()), wrapText(subtitleFace, text.Subtitle, area.Dx())...)
+
+		// Leave some room between the title and the subtitle
+		gap = 0
+		if hasTitle && hasSubtitle {
+			gap = int(subtitleSize * 0.4)
+		}
+
+		if fits(lines, area, gap) || math.Min(titleSize, subtitleSize) < minFontSize {
+			break
+		}
+	}
+
+	// Place the block of lines
+	height := gap
+	for _, line := range lines {
+		height += lineHeight(line.face)
+	}
+
+	top := area.Max.Y - height
+	switch text.Position {
+	case PositionTop:
+		top = area.Min.Y
+	case PositionCenter:
+		top = area.Min.Y + (area.Dy()-height)/2
+	}
+
+	textColor := text.Color
+	if textColor == nil {
+		textColor = contrastColor(img, image.Rect(area.Min.X, top, area.Max.X, top+height))
+	}
+
+	drawer := font.Drawer{Dst: img, Src: image.NewUniform(textColor)}
+	y := top
+	for i, line := range lines {
+		if i > 0 && line.face != lines[i-1].face {
+			y += gap
+		}
+
+		x := area.Min.X
+		switch text.Align {
+		case AlignCenter:
+			x += (area.Dx() - line.width) / 2
+		case AlignRight:
+			x = area.Max.X - line.width
+		}
+
+		drawer.Face = line.face
+		drawer.Dot = fixed.P(x, y+line.face.Metrics().Ascent.Ceil())
+		drawer.DrawString(line.text)
+
+		y += lineHeight(line.face)
+	}
+
+	return nil
+}
+
+// wrapText splits s into lines no wider than maxWidth, breaking at spaces
+// and explicit newlines. A single word wider than maxWidth gets its own line.
+func wrapText(face font.Face, s string, maxWidth int) []textLine {
+	var lines []textLine
+	for _, paragraph := range strings.Split(s, "\n") {
+		current := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if current != "" {
+				candidate = current + " " + word
+			}
+			if current != "" && font.MeasureString(face, candidate).Ceil() > maxWidth {
+				lines = append(lines, newTextLine(face, current))
+				candidate = word
+			}
+			current = candidate
+		}
+		if current != "" {
+			lines = append(lines, newTextLine(face, current))
+		}
+	}
+	return lines
+}
+
+func newTextLine(face font.Face, text string) textLine {
+	return textLine{face: face, text: text, width: font.MeasureString(face, text).Ceil()}
+}
+
+// lineHeight is the distance between the tops of two lines set in face
+func lineHeight(face font.Face) int {
+	return face.Metrics().Height.Ceil()
+}
+
+// fits reports whether the lines fit in area
+func fits(lines []textLine, area image.Rectangle, gap int) bool {
+	height := gap
+	for _, line := range lines {
+		if line.width > area.Dx() {
+			return false
+		}
+		height += lineHeight(line.face)
+	}
+	return height <= area.Dy()
+}
+
+// contrastColor returns black or white, whichever has the higher WCAG
+// contrast ratio with the average luminance of img inside r
+func contrastColor(img image.Image, r image.Rectangle) color.Color {
+	r = r.Intersect(img.Bounds())
+
+	var total float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			total += relativeLuminance(img.At(x, y))
+		}
+	}
+
+	luminance := 1.0
+	if pixels := r.Dx() * r.Dy(); pixels > 0 {
+		luminance = total / float64(pixels)
+	}
+
+	contrastWithWhite := 1.05 / (luminance + 0.05)
+	contrastWithBlack := (luminance + 0.05) / 0.05
+	if contrastWithWhite > contrastWithBlack {
+		return color.White
+	}
+	return color.Black
+}
+
+// relativeLuminance returns the WCAG relative luminance of c, from 0 to 1
+func relativeLuminance(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return 0.2126*linearize(r) + 0.7152*linearize(g) + 0.0722*linearize(b)
+}
+
+// linearize converts a 16-bit sRGB channel to linear light
+func linearize(v uint32) float64 {
+	s := float64(v) / 0xffff
+	if s <= 0.03928 {
+		return s / 12.92
+	}
+	return math.Pow((s+0.055)/1.055, 2.4)
+}
@@ -0,0 +1,109 @@
+package coverimage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// filled returns a 300x300 image of a single color
+func filled(c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 300, 300))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+// inkBounds returns the bounds of the pixels that differ from background
+func inkBounds(img *image.RGBA, background color.Color) image.Rectangle {
+	br, bg, bb, _ := background.RGBA()
+	var bounds image.Rectangle
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			if r != br || g != bg || b != bb {
+				bounds = bounds.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return bounds
+}
+
+func defaultText(title, subtitle string) Text {
+	return Text{
+		Title:        title,
+		Subtitle:     subtitle,
+		TitleSize:    DefaultTitleSize,
+		SubtitleSize: DefaultSubtitleSize,
+		Align:        AlignLeft,
+		Position:     PositionBottom,
+		Padding:      DefaultPadding,
+	}
+}
+
+func TestDrawTextStaysInsidePadding(t *testing.T) {
+	for _, align := range []string{AlignLeft, AlignCenter, AlignRight} {
+		for _, position := range []string{PositionTop, PositionCenter, PositionBottom} {
+			img := filled(color.Black)
+			text := defaultText("A very long playlist title that needs wrapping", "Subtitle")
+			text.Align, text.Position = align, position
+
+			if err := DrawText(img, text); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			ink := inkBounds(img, color.Black)
+			if ink.Empty() {
+				t.Fatalf("Expected text to be drawn for %s/%s", align, position)
+			}
+			if safe := img.Bounds().Inset(DefaultPadding); !ink.In(safe) {
+				t.Errorf("Text %v is outside of the safe area %v for %s/%s", ink, safe, align, position)
+			}
+		}
+	}
+}
+
+func TestDrawTextAlignment(t *testing.T) {
+	left, right := filled(color.Black), filled(color.Black)
+
+	text := defaultText("Mix", "")
+	if err := DrawText(left, text); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	text.Align = AlignRight
+	if err := DrawText(right, text); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if l, r := inkBounds(left, color.Black), inkBounds(right, color.Black); l.Min.X >= r.Min.X {
+		t.Errorf("Expected left aligned text %v to start before right aligned text %v", l, r)
+	}
+}
+
+func TestDrawTextContrast(t *testing.T) {
+	tests := map[color.Color]color.Color{
+		color.Black:                   color.White,
+		color.White:                   color.Black,
+		color.RGBA{255, 215, 0, 255}:  color.Black,
+		color.RGBA{70, 130, 180, 255}: color.Black,
+		color.RGBA{128, 0, 128, 255}:  color.White,
+		color.RGBA{29, 185, 84, 255}:  color.Black,
+		color.RGBA{47, 79, 79, 255}:   color.White,
+	}
+
+	for background, expected := range tests {
+		if got := contrastColor(filled(background), image.Rect(0, 0, 300, 300)); got != expected {
+			t.Errorf("contrastColor(%v) = %v, expected %v", background, got, expected)
+		}
+	}
+}
+
+func TestDrawTextWithoutText(t *testing.T) {
+	img := filled(color.Black)
+	if err := DrawText(img, defaultText("  ", "")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ink := inkBounds(img, color.Black); !ink.Empty() {
+		t.Errorf("Expected nothing to be drawn, got %v", ink)
+	}
+}
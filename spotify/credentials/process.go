@@ -0,0 +1,104 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// processTimeout bounds how long a credential process may run
+const processTimeout = 60 * time.Second
+
+// processTokenSource runs an external command that prints a JSON token
+type processTokenSource struct {
+	args []string
+}
+
+// NewProcessTokenSource returns a token source that runs the given command
+// line and parses the JSON token it prints on stdout. The command is split on
+// whitespace, honoring single and double quotes; it is not run through a shell.
+// Wrap the result in oauth2.ReuseTokenSource so the command only runs when
+// the previous token has expired.
+func NewProcessTokenSource(command string) (oauth2.TokenSource, error) {
+	args, err := SplitCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("credential_process is empty")
+	}
+
+	return &processTokenSource{args: args}, nil
+}
+
+// Token implements oauth2.TokenSource
+func (s *processTokenSource) Token() (*oauth2.Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), processTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.args[0], s.args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// stderr is deliberately left out, it may echo credentials
+		return nil, fmt.Errorf("credential_process %s failed: %w", s.args[0], err)
+	}
+
+	token, err := ParseToken(stdout.Bytes(), time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("credential_process %s: %w", s.args[0], err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("credential_process %s did not print an access_token", s.args[0])
+	}
+
+	return token, nil
+}
+
+// SplitCommand splits a command line into arguments, honoring single and
+// double quotes
+func SplitCommand(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range command {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command: %s", command)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
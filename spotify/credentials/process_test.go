@@ -0,0 +1,65 @@
+package credentials
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	cases := map[string][]string{
+		"helper get-token":                {"helper", "get-token"},
+		`helper --path "/My Vault/token"`: {"helper", "--path", "/My Vault/token"},
+		"  helper   'a b'  c ":            {"helper", "a b", "c"},
+		`helper ""`:                       {"helper", ""},
+	}
+
+	for command, expected := range cases {
+		args, err := SplitCommand(command)
+		if err != nil {
+			t.Errorf("Expected no error for %q, got %v", command, err)
+			continue
+		}
+		if !reflect.DeepEqual(args, expected) {
+			t.Errorf("Expected %q for %q, got %q", expected, command, args)
+		}
+	}
+
+	if _, err := SplitCommand(`helper "unterminated`); err == nil {
+		t.Error("Expected an error for an unterminated quote")
+	}
+}
+
+func TestProcessTokenSource(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("echo is a shell builtin on Windows")
+	}
+
+	source, err := NewProcessTokenSource(`echo '{"access_token":"from-process","expires_in":3600}'`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	token, err := source.Token()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if token.AccessToken != "from-process" || !token.Valid() {
+		t.Errorf("Unexpected token %+v", token)
+	}
+}
+
+func TestProcessTokenSourceRequiresAccessToken(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("echo is a shell builtin on Windows")
+	}
+
+	source, err := NewProcessTokenSource(`echo '{"refresh_token":"only-refresh"}'`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := source.Token(); err == nil {
+		t.Error("Expected an error when the process prints no access_token")
+	}
+}
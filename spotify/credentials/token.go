@@ -0,0 +1,87 @@
+// Package credentials loads Spotify OAuth tokens from sources other than the
+// provider configuration: a token file that is kept up to date as tokens
+// rotate, and an external credential process.
+package credentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Token is the JSON token format used by token files and credential processes.
+// It matches the token response of the Spotify Accounts service (and the
+// output of spotify_auth_proxy), with an optional absolute expiry.
+type Token struct {
+	AccessToken  string     `json:"access_token"`
+	TokenType    string     `json:"token_type,omitempty"`
+	Scope        string     `json:"scope,omitempty"`
+	ExpiresIn    int        `json:"expires_in,omitempty"`
+	RefreshToken string     `json:"refresh_token,omitempty"`
+	Expiry       *time.Time `json:"expiry,omitempty"`
+}
+
+// ParseToken decodes a JSON token. Relative expiries (expires_in) are
+// resolved against issuedAt, the time the token was obtained.
+func ParseToken(data []byte, issuedAt time.Time) (*oauth2.Token, error) {
+	var token Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("error parsing token JSON: %w", err)
+	}
+
+	if token.AccessToken == "" && token.RefreshToken == "" {
+		return nil, fmt.Errorf("token JSON contains neither an access_token nor a refresh_token")
+	}
+
+	return token.OAuth2(issuedAt), nil
+}
+
+// OAuth2 converts the token to an oauth2.Token
+func (t *Token) OAuth2(issuedAt time.Time) *oauth2.Token {
+	token := &oauth2.Token{
+		AccessToken:  t.AccessToken,
+		TokenType:    t.TokenType,
+		RefreshToken: t.RefreshToken,
+	}
+
+	switch {
+	case t.Expiry != nil:
+		token.Expiry = *t.Expiry
+	case t.ExpiresIn > 0:
+		token.Expiry = issuedAt.Add(time.Duration(t.ExpiresIn) * time.Second)
+	case t.AccessToken == "":
+		// A refresh token on its own must be exchanged right away
+		token.Expiry = issuedAt
+	}
+
+	if t.Scope != "" {
+		token = token.WithExtra(map[string]interface{}{"scope": t.Scope})
+	}
+
+	return token
+}
+
+// FromOAuth2 converts an oauth2.Token to the JSON token format
+func FromOAuth2(token *oauth2.Token, now time.Time) *Token {
+	result := &Token{
+		AccessToken:  token.AccessToken,
+		TokenType:    token.TokenType,
+		RefreshToken: token.RefreshToken,
+	}
+
+	if scope, ok := token.Extra("scope").(string); ok {
+		result.Scope = scope
+	}
+
+	if !token.Expiry.IsZero() {
+		expiry := token.Expiry.UTC()
+		result.Expiry = &expiry
+		if remaining := token.Expiry.Sub(now); remaining > 0 {
+			result.ExpiresIn = int(remaining / time.Second)
+		}
+	}
+
+	return result
+}
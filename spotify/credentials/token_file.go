@@ -0,0 +1,187 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/logging"
+)
+
+const (
+	// lockTimeout is how long to wait for another process to release the token file
+	lockTimeout = 30 * time.Second
+
+	// lockStaleAfter is the age after which a leftover lock file is considered abandoned
+	lockStaleAfter = 2 * time.Minute
+
+	// lockPollInterval is how often to retry acquiring the lock
+	lockPollInterval = 100 * time.Millisecond
+)
+
+// FileStore reads and writes a JSON token file. Writes are atomic and all
+// access is serialized across processes with a lock file next to the token.
+type FileStore struct {
+	Path string
+}
+
+// NewFileStore creates a store for the token file at the given path
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+// Exists reports whether the token file exists
+func (s *FileStore) Exists() bool {
+	_, err := os.Stat(s.Path)
+	return err == nil
+}
+
+// Load reads the token file
+func (s *FileStore) Load() (*oauth2.Token, error) {
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading token file: %w", err)
+	}
+
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading token file: %w", err)
+	}
+
+	// Save and spotify_auth_proxy write an absolute expiry. Files holding a
+	// raw token response only carry expires_in, which is resolved against
+	// the time the file was last written.
+	token, err := ParseToken(data, info.ModTime())
+	if err != nil {
+		return nil, fmt.Errorf("error reading token file %s: %w", s.Path, err)
+	}
+
+	return token, nil
+}
+
+// Save atomically replaces the token file with the given token
+func (s *FileStore) Save(token *oauth2.Token) error {
+	data, err := json.MarshalIndent(FromOAuth2(token, time.Now()), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding token: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), ".spotify-token-*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temporary token file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error setting token file permissions: %w", err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing token file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing token file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing token file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, s.Path); err != nil {
+		return fmt.Errorf("error replacing token file: %w", err)
+	}
+
+	return nil
+}
+
+// Lock acquires the cross-process lock for the token file and returns a
+// function that releases it
+func (s *FileStore) Lock() (func(), error) {
+	lockPath := s.Path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+
+	for {
+		lockFile, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			fmt.Fprintf(lockFile, "%d\n", os.Getpid())
+			lockFile.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("error creating token lock file: %w", err)
+		}
+
+		// Remove locks left behind by a process that crashed while holding them
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > lockStaleAfter {
+			logging.Warn("Removing stale token file lock", "path", lockPath)
+			os.Remove(lockPath)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for token file lock %s", lockPath)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// fileTokenSource hands out the token from a token file and refreshes it
+// when it expires, writing the rotated token back to the file
+type fileTokenSource struct {
+	store     *FileStore
+	refresher func(*oauth2.Token) oauth2.TokenSource
+}
+
+// NewFileTokenSource returns a token source backed by the given store. The
+// refresher builds the source that exchanges an expired token for a new one.
+// Wrap the result in oauth2.ReuseTokenSource to avoid reading the file on
+// every request.
+func NewFileTokenSource(store *FileStore, refresher func(*oauth2.Token) oauth2.TokenSource) oauth2.TokenSource {
+	return &fileTokenSource{store: store, refresher: refresher}
+}
+
+// Token implements oauth2.TokenSource
+func (s *fileTokenSource) Token() (*oauth2.Token, error) {
+	unlock, err := s.store.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Another process may have refreshed the token since it was last read
+	current, err := s.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if current.Valid() {
+		return current, nil
+	}
+
+	if current.RefreshToken == "" {
+		return nil, fmt.Errorf("token in %s has expired and has no refresh_token", s.store.Path)
+	}
+
+	// Force a refresh by dropping the expired access token
+	refreshed, err := s.refresher(&oauth2.Token{RefreshToken: current.RefreshToken}).Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Save(refreshed); err != nil {
+		return nil, err
+	}
+
+	if refreshed.RefreshToken != current.RefreshToken {
+		logging.Info("Stored rotated refresh token", "path", s.store.Path)
+	}
+
+	return refreshed, nil
+}
@@ -0,0 +1,143 @@
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// staticRefresher returns a refresher that always hands out the given token
+func staticRefresher(token *oauth2.Token, calls *int) func(*oauth2.Token) oauth2.TokenSource {
+	return func(*oauth2.Token) oauth2.TokenSource {
+		*calls++
+		return oauth2.StaticTokenSource(token)
+	}
+}
+
+func TestFileStoreLoadsAuthProxyFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	content := `{"access_token":"access","token_type":"Bearer","scope":"playlist-modify-public","expires_in":3600,"refresh_token":"refresh"}`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := NewFileStore(path).Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if token.AccessToken != "access" || token.RefreshToken != "refresh" {
+		t.Errorf("Unexpected token %+v", token)
+	}
+	if token.Extra("scope") != "playlist-modify-public" {
+		t.Errorf("Expected scope to be kept, got %v", token.Extra("scope"))
+	}
+	if !token.Valid() {
+		t.Error("Expected a freshly written token to be valid")
+	}
+}
+
+func TestFileStoreSaveIsPrivate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	store := NewFileStore(path)
+
+	token := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)}
+	if err := store.Save(token); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions 0600, got %v", info.Mode().Perm())
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if loaded.RefreshToken != "refresh" {
+		t.Errorf("Expected refresh token 'refresh', got '%s'", loaded.RefreshToken)
+	}
+}
+
+func TestFileTokenSourceReusesValidToken(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "token.json"))
+	if err := store.Save(&oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	source := NewFileTokenSource(store, staticRefresher(&oauth2.Token{AccessToken: "new"}, &calls))
+
+	token, err := source.Token()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if token.AccessToken != "access" || calls != 0 {
+		t.Errorf("Expected the stored token without a refresh, got '%s' after %d refreshes", token.AccessToken, calls)
+	}
+}
+
+func TestFileTokenSourceWritesBackRotatedToken(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "token.json"))
+	if err := store.Save(&oauth2.Token{AccessToken: "old", RefreshToken: "old-refresh", Expiry: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	rotated := &oauth2.Token{AccessToken: "new", RefreshToken: "new-refresh", Expiry: time.Now().Add(time.Hour)}
+	source := NewFileTokenSource(store, staticRefresher(rotated, &calls))
+
+	token, err := source.Token()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if token.AccessToken != "new" || calls != 1 {
+		t.Errorf("Expected a refreshed token, got '%s' after %d refreshes", token.AccessToken, calls)
+	}
+
+	stored, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RefreshToken != "new-refresh" {
+		t.Errorf("Expected the rotated refresh token to be stored, got '%s'", stored.RefreshToken)
+	}
+
+	if _, err := os.Stat(store.Path + ".lock"); !os.IsNotExist(err) {
+		t.Error("Expected the lock file to be released")
+	}
+}
+
+func TestFileStoreLockIsExclusive(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "token.json"))
+
+	unlock, err := store.Lock()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		unlockSecond, err := store.Lock()
+		if err == nil {
+			unlockSecond()
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Expected the second lock to wait for the first one")
+	case <-time.After(3 * lockPollInterval):
+	}
+
+	unlock()
+	<-acquired
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/zmb3/spotify/v2"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/logging"
 )
 
 func dataSourceFeaturedPlaylists() *schema.Resource {
@@ -98,7 +100,7 @@ func dataSourceFeaturedPlaylistsRead(ctx context.Context, d *schema.ResourceData
 	message, playlistPage, err := client.FeaturedPlaylists(ctx, opts...)
 	if err != nil {
 		// Log the error but don't fail - allow empty results
-		fmt.Printf("Warning: error getting featured playlists: %s\n", err)
+		logging.DefaultLogger.WithContext(ctx).Warn("Error getting featured playlists", "error", err.Error())
 		// Set an empty message and continue with empty playlists
 		message = "No featured playlists available"
 		// Return empty results instead of error
@@ -8,6 +8,8 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/zmb3/spotify/v2"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/logging"
 )
 
 func dataSourceNewReleases() *schema.Resource {
@@ -73,7 +75,7 @@ func dataSourceNewReleasesRead(ctx context.Context, d *schema.ResourceData, m in
 	albumPage, err := client.NewReleases(ctx, opts...)
 	if err != nil {
 		// Log the error but don't fail - allow empty results
-		fmt.Printf("Warning: error getting new releases: %s\n", err)
+		logging.DefaultLogger.WithContext(ctx).Warn("Error getting new releases", "error", err.Error())
 		// Return empty results instead of error
 		d.Set("albums", []map[string]interface{}{})
 		d.Set("album_ids", []string{})
@@ -12,6 +12,8 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/zmb3/spotify/v2"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/logging"
 )
 
 // min returns the smaller of x or y.
@@ -335,7 +337,7 @@ func dataSourceSpotifyTracksRead(ctx context.Context, d *schema.ResourceData, m
 		detailedErr := fmt.Errorf("error getting recommendations: %s\nSeeds used: %+v\nMood: %s", err, seeds, mood)
 		
 		// Log the error details for debugging
-		fmt.Printf("Spotify API Error: %s\nFalling back to Search API\n", detailedErr)
+		logging.DefaultLogger.WithContext(ctx).Warn("Error getting recommendations, falling back to the Search API", "error", detailedErr.Error())
 		
 		// Build a search query based on genre/mood
 		searchQuery := ""
@@ -393,7 +395,7 @@ func dataSourceSpotifyTracksRead(ctx context.Context, d *schema.ResourceData, m
 			searchQuery = "tag:popular year:2020-2023 popularity:>75"
 		}
 		
-		fmt.Printf("Using search query: %s\n", searchQuery)
+		logging.DefaultLogger.WithContext(ctx).Debug("Searching for tracks", "query", searchQuery)
 		
 		// Use the Search API instead
 		searchResult, err := client.Search(ctx, searchQuery, spotify.SearchTypeTrack, spotify.Limit(limit))
@@ -493,7 +495,7 @@ func dataSourceSpotifyTracksRead(ctx context.Context, d *schema.ResourceData, m
 	if len(recommendations.Tracks) == 0 {
 		// No tracks were returned, provide a helpful message
 		detailedErr := fmt.Errorf("no tracks returned for the given criteria. Seeds used: %+v, Mood: %s", seeds, mood)
-		fmt.Printf("Spotify API Warning: %s\n", detailedErr)
+		logging.DefaultLogger.WithContext(ctx).Warn("No recommendations returned", "error", detailedErr.Error())
 		
 		// Continue execution but log the warning
 	}
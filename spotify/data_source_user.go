@@ -50,6 +50,13 @@ func dataSourceUser() *schema.Resource {
 }
 
 func dataSourceUserRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := requireUserAuth(m, "spotify_user"); diags != nil {
+		return diags
+	}
+	if diags := requireScopes(m, "spotify_user"); diags != nil {
+		return diags
+	}
+
 	var diags diag.Diagnostics
 	client := m.(*ProviderClient).SpotifyClient
 
@@ -74,4 +81,4 @@ func dataSourceUserRead(ctx context.Context, d *schema.ResourceData, m interface
 	d.Set("images", images)
 
 	return diags
-}
\ No newline at end of file
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/zmb3/spotify/v2"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/logging"
 )
 
 func dataSourceUserPreferences() *schema.Resource {
@@ -58,6 +61,13 @@ func dataSourceUserPreferences() *schema.Resource {
 }
 
 func dataSourceUserPreferencesRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := requireUserAuth(m, "spotify_user_preferences"); diags != nil {
+		return diags
+	}
+	if diags := requireScopes(m, "spotify_user_preferences"); diags != nil {
+		return diags
+	}
+
 	var diags diag.Diagnostics
 	client := m.(*ProviderClient).SpotifyClient
 	
@@ -95,7 +105,7 @@ func dataSourceUserPreferencesRead(ctx context.Context, d *schema.ResourceData,
 	
 	// Instead of trying to match with available genres, just use the top genres directly
 	// This avoids the API call that was causing the 404 error
-	fmt.Printf("Using top genres directly as seed genres\n")
+	logging.DefaultLogger.WithContext(ctx).Debug("Using top genres directly as seed genres", "genres", strings.Join(topGenres, ","))
 	
 	// Use top genres directly as suggested seed genres
 	suggestedGenres := topGenres
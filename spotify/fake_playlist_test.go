@@ -0,0 +1,265 @@
+package spotify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/reconcile"
+)
+
+const (
+	testPlaylistID   = "37i9dQZF1DXcBWIGoYBM5M"
+	testPlaylistName = "Test playlist"
+	testTrackA       = "4uLU6hMCjMI75M1A2tKUQC"
+	testTrackB       = "1301WleyT98MSxVHPZCA6M"
+)
+
+// testTrackURIs returns n distinct track URIs, starting from the given number
+func testTrackURIs(start, n int) []string {
+	uris := make([]string, n)
+	for i := range uris {
+		uris[i] = fmt.Sprintf("spotify:track:%022d", start+i)
+	}
+	return uris
+}
+
+// fakePlaylist serves the playlist endpoints of the Spotify Web API for a
+// single playlist. Edits sent with a snapshot ID must refer to the current
+// one, and edits Spotify would reject fail the test.
+type fakePlaylist struct {
+	t  *testing.T
+	mu sync.Mutex
+	// uris are the items of the playlist, "" for an unavailable item
+	uris     []string
+	snapshot int
+
+	// removals and inserts hold the URIs sent by each remove and insert request
+	removals [][]string
+	inserts  [][]string
+	// details holds the body of each request changing the playlist details
+	details []map[string]interface{}
+	// coverUploads counts the cover images uploaded
+	coverUploads int
+	// unfollowed is set once the playlist is unfollowed
+	unfollowed bool
+	// scans counts the reads of the first page of items
+	scans int
+	// changeDuringScans makes the playlist change while each of the first
+	// scans is read, as if edited concurrently
+	changeDuringScans int
+	// failInsert makes the insert request with this number, counting from 1, fail
+	failInsert int
+}
+
+// newFakePlaylist starts a fake Spotify Web API holding a playlist of the
+// given tracks and returns a provider client talking to it
+func newFakePlaylist(t *testing.T, trackIDs ...string) (*fakePlaylist, *ProviderClient) {
+	t.Helper()
+
+	uris := make([]string, len(trackIDs))
+	for i, id := range trackIDs {
+		uris[i] = "spotify:track:" + id
+	}
+	return newFakePlaylistOf(t, uris)
+}
+
+// newFakePlaylistOf is newFakePlaylist for a playlist of the given URIs, ""
+// standing for an unavailable item
+func newFakePlaylistOf(t *testing.T, uris []string) (*fakePlaylist, *ProviderClient) {
+	t.Helper()
+
+	playlist := &fakePlaylist{t: t, uris: append([]string(nil), uris...)}
+
+	server := httptest.NewServer(playlist)
+	t.Cleanup(server.Close)
+
+	return playlist, &ProviderClient{
+		SpotifyClient: spotify.New(server.Client(), spotify.WithBaseURL(server.URL+"/v1/")),
+		HTTPClient:    server.Client(),
+		APIBaseURL:    server.URL,
+	}
+}
+
+func (p *fakePlaylist) items() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.uris...)
+}
+
+func (p *fakePlaylist) snapshotID() string {
+	return "snapshot-" + strconv.Itoa(p.snapshot)
+}
+
+// checkSnapshot fails the test when an edit is not sent with the current snapshot ID
+func (p *fakePlaylist) checkSnapshot(op, snapshotID string) {
+	if snapshotID != p.snapshotID() {
+		p.t.Errorf("%s sent with snapshot %q, playlist is at %q", op, snapshotID, p.snapshotID())
+	}
+}
+
+// checkBatch fails the test when a request carries more items than Spotify accepts
+func (p *fakePlaylist) checkBatch(op string, n int) {
+	if n > reconcile.MaxBatchSize {
+		p.t.Errorf("%s sent with %d items, Spotify accepts at most %d", op, n, reconcile.MaxBatchSize)
+	}
+}
+
+func (p *fakePlaylist) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	playlistPath := "/v1/playlists/" + testPlaylistID
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/me":
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "user"})
+
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/users/user/playlists":
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":            testPlaylistID,
+			"snapshot_id":   p.snapshotID(),
+			"external_urls": map[string]string{"spotify": "https://open.spotify.com/playlist/" + testPlaylistID},
+		})
+
+	case r.Method == http.MethodGet && r.URL.Path == playlistPath:
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": testPlaylistID, "name": testPlaylistName, "snapshot_id": p.snapshotID()})
+
+	case r.Method == http.MethodPut && r.URL.Path == playlistPath+"/images":
+		p.coverUploads++
+		w.WriteHeader(http.StatusAccepted)
+
+	case r.Method == http.MethodDelete && r.URL.Path == playlistPath+"/followers":
+		p.unfollowed = true
+
+	case r.Method == http.MethodPut && r.URL.Path == playlistPath:
+		if r.Header.Get("Content-Type") != "application/json" {
+			p.t.Errorf("Details sent as %q, expected application/json", r.Header.Get("Content-Type"))
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		p.details = append(p.details, body)
+
+	case r.Method == http.MethodGet && r.URL.Path == playlistPath+"/tracks":
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		items := []interface{}{}
+		for i := offset; i < len(p.uris) && i < offset+limit; i++ {
+			var track interface{}
+			if p.uris[i] != "" {
+				id := strings.TrimPrefix(p.uris[i], "spotify:track:")
+				track = map[string]interface{}{"type": "track", "id": id, "uri": p.uris[i], "name": "Track " + id}
+			}
+			items = append(items, map[string]interface{}{
+				"added_at": "2024-01-01T00:00:00Z",
+				"added_by": map[string]interface{}{"id": "user"},
+				"track":    track,
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": items, "total": len(p.uris), "offset": offset, "limit": limit})
+
+		if offset == 0 {
+			p.scans++
+			if p.scans <= p.changeDuringScans {
+				p.snapshot++
+			}
+		}
+
+	case r.Method == http.MethodPost && r.URL.Path == playlistPath+"/tracks":
+		var body struct {
+			URIs     []string `json:"uris"`
+			Position *int     `json:"position"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		p.checkBatch("Insert", len(body.URIs))
+		p.inserts = append(p.inserts, body.URIs)
+
+		if len(p.inserts) == p.failInsert {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"status": 400, "message": "Insert failed"}})
+			return
+		}
+
+		position := len(p.uris)
+		if body.Position != nil {
+			position = *body.Position
+		}
+		p.uris = append(p.uris[:position:position], append(body.URIs, p.uris[position:]...)...)
+		p.snapshot++
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"snapshot_id": p.snapshotID()})
+
+	case r.Method == http.MethodDelete && r.URL.Path == playlistPath+"/tracks":
+		var body struct {
+			Tracks []struct {
+				URI       string `json:"uri"`
+				Positions []int  `json:"positions"`
+			} `json:"tracks"`
+			SnapshotID string `json:"snapshot_id"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		p.checkSnapshot("Removal", body.SnapshotID)
+
+		// Positions refer to the playlist before the removal
+		var removed []string
+		remove := map[int]bool{}
+		for _, track := range body.Tracks {
+			for _, position := range track.Positions {
+				if position >= len(p.uris) || p.uris[position] != track.URI {
+					p.t.Errorf("Removal of %q at %d does not match the playlist", track.URI, position)
+					continue
+				}
+				remove[position] = true
+				removed = append(removed, track.URI)
+			}
+		}
+		p.checkBatch("Removal", len(removed))
+		p.removals = append(p.removals, removed)
+
+		var kept []string
+		for i, uri := range p.uris {
+			if !remove[i] {
+				kept = append(kept, uri)
+			}
+		}
+		p.uris = kept
+		p.snapshot++
+		json.NewEncoder(w).Encode(map[string]string{"snapshot_id": p.snapshotID()})
+
+	case r.Method == http.MethodPut && r.URL.Path == playlistPath+"/tracks":
+		var body struct {
+			RangeStart   int    `json:"range_start"`
+			RangeLength  int    `json:"range_length"`
+			InsertBefore int    `json:"insert_before"`
+			SnapshotID   string `json:"snapshot_id"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		p.checkSnapshot("Reorder", body.SnapshotID)
+		if body.RangeLength == 0 {
+			body.RangeLength = 1
+		}
+
+		moved := append([]string(nil), p.uris[body.RangeStart:body.RangeStart+body.RangeLength]...)
+		rest := append(p.uris[:body.RangeStart:body.RangeStart], p.uris[body.RangeStart+body.RangeLength:]...)
+		insertBefore := body.InsertBefore
+		if insertBefore > body.RangeStart {
+			insertBefore -= body.RangeLength
+		}
+		p.uris = append(rest[:insertBefore:insertBefore], append(moved, rest[insertBefore:]...)...)
+		p.snapshot++
+		json.NewEncoder(w).Encode(map[string]string{"snapshot_id": p.snapshotID()})
+
+	default:
+		p.t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
@@ -84,6 +84,9 @@ func (l *Logger) formatMessage(level LogLevel, msg string, args map[string]inter
 	if len(args) > 0 {
 		contextStrings := make([]string, 0, len(args))
 		for k, v := range args {
+			if isSensitiveKey(k) {
+				v = redactedPlaceholder
+			}
 			contextStrings = append(contextStrings, fmt.Sprintf("%s=%v", k, v))
 		}
 		base = fmt.Sprintf("%s {%s}", base, strings.Join(contextStrings, ", "))
@@ -99,7 +102,8 @@ func (l *Logger) log(level LogLevel, msg string, args map[string]interface{}) {
 		return
 	}
 
-	log.Println(l.formatMessage(level, msg, args))
+	// Never emit secrets, whatever the caller passed in
+	log.Println(Redact(l.formatMessage(level, msg, args)))
 }
 
 // Debug logs a debug message
@@ -185,4 +189,4 @@ func argsToMap(args ...interface{}) map[string]interface{} {
 	}
 
 	return result
-}
\ No newline at end of file
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// redactedPlaceholder replaces any secret value in log output
+const redactedPlaceholder = "[REDACTED]"
+
+// minSecretLength avoids redacting short, common substrings
+const minSecretLength = 6
+
+var (
+	secretsMu sync.RWMutex
+	secrets   = map[string]struct{}{}
+
+	// sensitiveKeySuffixes end the argument keys whose values are always
+	// redacted, such as "refresh_token" or "clientSecret". Matching the end
+	// of the key keeps harmless keys such as "token_type" readable.
+	sensitiveKeySuffixes = []string{"token", "secret", "password", "authorization", "api_key", "apikey", "credential", "credentials", "verifier"}
+
+	// secretPatterns match credentials embedded in free-form text such as
+	// error messages, HTTP headers, query strings and JSON bodies
+	secretPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(bearer|basic)\s+[A-Za-z0-9\-._~+/]+=*`),
+		regexp.MustCompile(`(?i)((?:access_token|refresh_token|client_secret|code_verifier)["']?\s*[:=]\s*["']?)[^"'&\s,}]+`),
+	}
+)
+
+// RegisterSecret marks a value that must never appear in log output.
+// Values shorter than a few characters are ignored.
+func RegisterSecret(secret string) {
+	if len(secret) < minSecretLength {
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secrets[secret] = struct{}{}
+}
+
+// Redact removes registered secrets and well-known credential patterns from s
+func Redact(s string) string {
+	secretsMu.RLock()
+	for secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redactedPlaceholder)
+	}
+	secretsMu.RUnlock()
+
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllStringFunc(s, func(match string) string {
+			groups := pattern.FindStringSubmatch(match)
+			if len(groups) > 1 && groups[1] != "" && strings.ContainsAny(groups[1], ":=") {
+				return groups[1] + redactedPlaceholder
+			}
+			return strings.Fields(match)[0] + " " + redactedPlaceholder
+		})
+	}
+
+	return s
+}
+
+// isSensitiveKey reports whether a log argument key names a secret
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, suffix := range sensitiveKeySuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,307 @@
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/logging"
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/reconcile"
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/spotifyuri"
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/utils"
+)
+
+// maxConsistentReadAttempts bounds how often a playlist is re-read when it
+// changes while its items are being paged through
+const maxConsistentReadAttempts = 3
+
+// getPlaylistItems returns every item in the playlist, in order, along with
+// the snapshot ID they belong to. Items are read through the provider's
+// playlist items cache, so resources reading the same playlist at the same
+// snapshot share a single scan. The returned slice must not be modified.
+func getPlaylistItems(ctx context.Context, client *ProviderClient, playlistID spotify.ID) ([]spotify.PlaylistItem, string, error) {
+	for attempt := 1; ; attempt++ {
+		playlist, err := client.SpotifyClient.GetPlaylist(ctx, playlistID, spotify.Fields("snapshot_id"))
+		if err != nil {
+			return nil, "", err
+		}
+
+		items, err := client.playlistItems.get(ctx, client.SpotifyClient, playlistID, playlist.SnapshotID)
+		if err == nil {
+			return items, playlist.SnapshotID, nil
+		}
+		if !errors.Is(err, errPlaylistChanged) {
+			return nil, "", err
+		}
+		if attempt == maxConsistentReadAttempts {
+			return nil, "", fmt.Errorf("playlist %s kept changing while its items were read, it is being edited concurrently", playlistID)
+		}
+	}
+}
+
+// unavailableItemPrefix starts the placeholders standing in for unavailable
+// items, which are never valid Spotify URIs
+const unavailableItemPrefix = "unavailable:"
+
+// getPlaylistItemURIs returns the URIs of every item in the playlist, in
+// order, along with the snapshot ID they belong to. Unavailable items have no
+// URI, so they can be neither matched nor removed; they get a placeholder
+// unique to their position, which applyPlaylistItems keeps in place.
+func getPlaylistItemURIs(ctx context.Context, client *ProviderClient, playlistID spotify.ID) ([]string, string, error) {
+	items, snapshotID, err := getPlaylistItems(ctx, client, playlistID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	uris := playlistItemURIs(items)
+	for i, uri := range uris {
+		if uri == "" {
+			uris[i] = fmt.Sprintf("%s%d", unavailableItemPrefix, i)
+		}
+	}
+	return uris, snapshotID, nil
+}
+
+// unavailableItemPositions returns the positions of the placeholders
+// getPlaylistItemURIs gives unavailable items
+func unavailableItemPositions(uris []string) []int {
+	var positions []int
+	for i, uri := range uris {
+		if strings.HasPrefix(uri, unavailableItemPrefix) {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+// playlistItemURIs returns the URIs of the items: tracks, episodes and local
+// files alike. Items Spotify returns without any content, such as tracks
+// unavailable in the user's market, are returned as empty strings so the
+// positions of the other items are preserved.
+func playlistItemURIs(items []spotify.PlaylistItem) []string {
+	uris := make([]string, len(items))
+	for i, item := range items {
+		uris[i] = playlistItemURI(item)
+	}
+	return uris
+}
+
+// playlistItemURI returns the URI of a playlist item, or "" if it has no content
+func playlistItemURI(item spotify.PlaylistItem) string {
+	switch {
+	case item.Track.Track != nil:
+		return string(item.Track.Track.URI)
+	case item.Track.Episode != nil:
+		return string(item.Track.Episode.URI)
+	}
+	return ""
+}
+
+// nthOccurrence returns the position of the n-th (0-based) occurrence of uri, or -1
+func nthOccurrence(uris []string, uri string, n int) int {
+	for i, u := range uris {
+		if u != uri {
+			continue
+		}
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return -1
+}
+
+// expandPlaylistItems normalizes the configured playlist items to canonical URIs
+func expandPlaylistItems(items []interface{}) ([]string, error) {
+	result := make([]string, len(items))
+	for i, v := range items {
+		uri, err := spotifyuri.NormalizePlaylistItem(v.(string))
+		if err != nil {
+			return nil, err
+		}
+		result[i] = uri
+	}
+	return result, nil
+}
+
+// checkAddablePlaylistItems returns an error for local files, which the
+// Spotify Web API cannot add to playlists
+func checkAddablePlaylistItems(uris []string) error {
+	for _, uri := range uris {
+		if spotifyuri.IsLocal(uri) {
+			return fmt.Errorf("%s is a local file, local files can only be added to a playlist with the Spotify desktop app", uri)
+		}
+	}
+	return nil
+}
+
+// appendPlaylistItems adds the items to the end of the playlist, in order,
+// in batches the Spotify API accepts. Returns how many items were added,
+// which is less than len(uris) when err is set, and the last snapshot ID.
+func appendPlaylistItems(ctx context.Context, client *ProviderClient, playlistID spotify.ID, uris []string) (int, string, error) {
+	added := 0
+	snapshotID := ""
+
+	for added < len(uris) {
+		end := added + reconcile.MaxBatchSize
+		if end > len(uris) {
+			end = len(uris)
+		}
+
+		// Keep the snapshot ID of the last batch added if this one fails
+		batchSnapshotID, err := insertPlaylistItems(ctx, client, playlistID, uris[added:end], -1)
+		if err != nil {
+			return added, snapshotID, err
+		}
+		snapshotID = batchSnapshotID
+		added = end
+	}
+
+	return added, snapshotID, nil
+}
+
+// reconcilePlaylistItems edits the playlist so it holds the desired URIs, in
+// order, keeping the items that are already in it. Returns the final
+// snapshot ID.
+func reconcilePlaylistItems(ctx context.Context, client *ProviderClient, playlistID spotify.ID, desired []string) (string, error) {
+	current, snapshotID, err := getPlaylistItemURIs(ctx, client, playlistID)
+	if err != nil {
+		return "", fmt.Errorf("error reading current playlist items: %w", err)
+	}
+
+	return applyPlaylistItems(ctx, client, playlistID, current, snapshotID, desired)
+}
+
+// applyPlaylistItems edits the playlist from current, read at snapshotID, to
+// desired with the fewest steps the reconcile planner finds. Each step is sent
+// with the snapshot ID returned by the previous one, so positions are
+// interpreted against the playlist as this plan left it. Unavailable items
+// stay where they are. Returns the final snapshot ID.
+func applyPlaylistItems(ctx context.Context, client *ProviderClient, playlistID spotify.ID, current []string, snapshotID string, desired []string) (string, error) {
+	logger := logging.DefaultLogger.WithContext(ctx)
+
+	unavailable := unavailableItemPositions(current)
+	if len(unavailable) > 0 {
+		logger.Warn("Keeping unavailable items in place, Spotify cannot remove items without a URI",
+			"playlist_id", string(playlistID),
+			"unavailable_count", len(unavailable),
+		)
+		desired = reconcile.Pin(current, desired, unavailable)
+	}
+
+	var err error
+	steps := reconcile.Plan(current, desired)
+	for _, step := range steps {
+		if step.Op == reconcile.Insert {
+			if err := checkAddablePlaylistItems(step.URIs); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	logger.Info("Reconciling playlist items",
+		"playlist_id", string(playlistID),
+		"current_count", len(current),
+		"desired_count", len(desired),
+		"steps", len(steps),
+	)
+
+	for i, step := range steps {
+		switch step.Op {
+		case reconcile.Remove:
+			items := make([]spotify.TrackToRemove, len(step.Removals))
+			for j, removal := range step.Removals {
+				items[j] = spotify.TrackToRemove{URI: removal.URI, Positions: removal.Positions}
+			}
+			snapshotID, err = client.SpotifyClient.RemoveTracksFromPlaylistOpt(ctx, playlistID, items, snapshotID)
+
+		case reconcile.Move:
+			snapshotID, err = client.SpotifyClient.ReorderPlaylistTracks(ctx, playlistID, spotify.PlaylistReorderOptions{
+				RangeStart:   spotify.Numeric(step.RangeStart),
+				RangeLength:  spotify.Numeric(step.RangeLength),
+				InsertBefore: spotify.Numeric(step.InsertBefore),
+				SnapshotID:   snapshotID,
+			})
+
+		case reconcile.Insert:
+			snapshotID, err = insertPlaylistItems(ctx, client, playlistID, step.URIs, step.Position)
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("error applying step %d of %d (%s) to playlist %s: %w", i+1, len(steps), step.Op, playlistID, err)
+		}
+	}
+
+	return snapshotID, nil
+}
+
+// insertPlaylistItems adds items at the given position, or appends them when
+// position is negative. The zmb3/spotify client only appends tracks, so the
+// request is made directly. Returns the new snapshot ID.
+func insertPlaylistItems(ctx context.Context, client *ProviderClient, playlistID spotify.ID, uris []string, position int) (string, error) {
+	payload := map[string]interface{}{
+		"uris": uris,
+	}
+	if position >= 0 {
+		payload["position"] = position
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", client.apiURL(fmt.Sprintf("playlists/%s/tracks", playlistID)), bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer utils.HandleResponseBodyClose(ctx, resp)
+
+	return decodeSnapshotResponse(resp)
+}
+
+// decodeSnapshotResponse returns the snapshot ID of a playlist edit response,
+// or the Spotify error it carries
+func decodeSnapshotResponse(resp *http.Response) (string, error) {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", decodeAPIError(resp.StatusCode, data)
+	}
+
+	var result struct {
+		SnapshotID string `json:"snapshot_id"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("error parsing response: %s", err)
+	}
+
+	return result.SnapshotID, nil
+}
+
+// decodeAPIError returns the Spotify error carried by a failed response body
+func decodeAPIError(status int, data []byte) error {
+	var apiErr struct {
+		Error spotify.Error `json:"error"`
+	}
+	if err := json.Unmarshal(data, &apiErr); err != nil || apiErr.Error.Message == "" {
+		return spotify.Error{Message: string(data), Status: status}
+	}
+	return apiErr.Error
+}
@@ -0,0 +1,168 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/zmb3/spotify/v2"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/logging"
+)
+
+// maxTracksPerRequest is the maximum number of IDs GetTracks accepts
+const maxTracksPerRequest = 50
+
+// errPlaylistChanged is returned when a playlist changes while it is being scanned
+var errPlaylistChanged = errors.New("playlist changed while its items were read")
+
+// playlistItemsCache shares playlist scans between the resources of a plan or
+// apply. Entries are keyed by playlist ID and snapshot ID, so any edit to a
+// playlist makes the next read scan it again. Only the latest snapshot of
+// each playlist is kept.
+type playlistItemsCache struct {
+	mu      sync.Mutex
+	entries map[spotify.ID]*playlistItemsEntry
+}
+
+// playlistItemsEntry is a scan of a playlist at one snapshot, done is closed
+// once items or err are set
+type playlistItemsEntry struct {
+	snapshotID string
+	done       chan struct{}
+	items      []spotify.PlaylistItem
+	err        error
+}
+
+// get returns the items of the playlist at the given snapshot. Concurrent
+// callers asking for the same snapshot wait for a single scan. The returned
+// slice is shared and must not be modified.
+func (c *playlistItemsCache) get(ctx context.Context, client *spotify.Client, playlistID spotify.ID, snapshotID string) ([]spotify.PlaylistItem, error) {
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = map[spotify.ID]*playlistItemsEntry{}
+	}
+
+	entry, ok := c.entries[playlistID]
+	if !ok || entry.snapshotID != snapshotID {
+		entry = &playlistItemsEntry{snapshotID: snapshotID, done: make(chan struct{})}
+		c.entries[playlistID] = entry
+		c.mu.Unlock()
+
+		entry.items, entry.err = scanPlaylistSnapshot(ctx, client, playlistID, snapshotID)
+		close(entry.done)
+
+		if entry.err != nil {
+			c.forget(playlistID, entry)
+		}
+		return entry.items, entry.err
+	}
+	c.mu.Unlock()
+
+	logging.DefaultLogger.WithContext(ctx).Debug("Using cached playlist items", "playlist_id", string(playlistID), "snapshot_id", snapshotID)
+
+	select {
+	case <-entry.done:
+		return entry.items, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// forget drops a failed entry so the next caller scans again
+func (c *playlistItemsCache) forget(playlistID spotify.ID, entry *playlistItemsEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries[playlistID] == entry {
+		delete(c.entries, playlistID)
+	}
+}
+
+// scanPlaylistSnapshot scans the playlist and checks that it is still at the
+// given snapshot afterwards, so the items can be cached under it
+func scanPlaylistSnapshot(ctx context.Context, client *spotify.Client, playlistID spotify.ID, snapshotID string) ([]spotify.PlaylistItem, error) {
+	items, err := scanPlaylistItems(ctx, client, playlistID)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := client.GetPlaylist(ctx, playlistID, spotify.Fields("snapshot_id"))
+	if err != nil {
+		return nil, err
+	}
+	if after.SnapshotID != snapshotID {
+		return nil, errPlaylistChanged
+	}
+
+	return items, nil
+}
+
+// scanPlaylistItems pages through every item of the playlist. Tracks returned
+// without metadata are looked up in batches.
+func scanPlaylistItems(ctx context.Context, client *spotify.Client, playlistID spotify.ID) ([]spotify.PlaylistItem, error) {
+	var items []spotify.PlaylistItem
+	limit := 100
+	offset := 0
+
+	for {
+		page, err := client.GetPlaylistItems(ctx, playlistID, spotify.Limit(limit), spotify.Offset(offset))
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, page.Items...)
+
+		if len(page.Items) < limit {
+			break
+		}
+
+		offset += limit
+	}
+
+	if err := fillTrackMetadata(ctx, client, items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// fillTrackMetadata looks up, in batches, the tracks Spotify returned without
+// their name, such as relinked or restricted tracks
+func fillTrackMetadata(ctx context.Context, client *spotify.Client, items []spotify.PlaylistItem) error {
+	var ids []spotify.ID
+	indexes := map[spotify.ID][]int{}
+	for i, item := range items {
+		track := item.Track.Track
+		if track == nil || item.IsLocal || track.ID == "" || track.Name != "" {
+			continue
+		}
+		if _, ok := indexes[track.ID]; !ok {
+			ids = append(ids, track.ID)
+		}
+		indexes[track.ID] = append(indexes[track.ID], i)
+	}
+
+	for start := 0; start < len(ids); start += maxTracksPerRequest {
+		end := start + maxTracksPerRequest
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		tracks, err := client.GetTracks(ctx, ids[start:end])
+		if err != nil {
+			return err
+		}
+
+		for _, track := range tracks {
+			if track == nil {
+				continue
+			}
+			for _, i := range indexes[track.ID] {
+				items[i].Track.Track = track
+			}
+		}
+	}
+
+	return nil
+}
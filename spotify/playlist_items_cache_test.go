@@ -0,0 +1,86 @@
+package spotify
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPlaylistItemsCacheSharesScans(t *testing.T) {
+	playlist, client := newFakePlaylist(t, testTrackA, testTrackB)
+	var cache playlistItemsCache
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			items, err := cache.get(context.Background(), client.SpotifyClient, testPlaylistID, playlist.snapshotID())
+			if err != nil || len(items) != 2 {
+				t.Errorf("Expected the 2 items of the playlist, got %d items and %v", len(items), err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := cache.get(context.Background(), client.SpotifyClient, testPlaylistID, playlist.snapshotID()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if playlist.scans != 1 {
+		t.Errorf("Expected a single scan at the same snapshot, got %d", playlist.scans)
+	}
+}
+
+func TestPlaylistItemsCacheScansNewSnapshot(t *testing.T) {
+	playlist, client := newFakePlaylist(t, testTrackA)
+	var cache playlistItemsCache
+
+	if _, err := cache.get(context.Background(), client.SpotifyClient, testPlaylistID, playlist.snapshotID()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, _, err := appendPlaylistItems(context.Background(), client, testPlaylistID, []string{"spotify:track:" + testTrackB}); err != nil {
+		t.Fatalf("Expected the track to be added, got %v", err)
+	}
+
+	items, err := cache.get(context.Background(), client.SpotifyClient, testPlaylistID, playlist.snapshotID())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if playlist.scans != 2 {
+		t.Errorf("Expected the new snapshot to be scanned, got %d scans", playlist.scans)
+	}
+	if len(items) != 2 {
+		t.Errorf("Expected the items of the new snapshot, got %d items", len(items))
+	}
+}
+
+func TestGetPlaylistItemsRetriesChangedPlaylist(t *testing.T) {
+	playlist, client := newFakePlaylist(t, testTrackA, testTrackB)
+	playlist.changeDuringScans = 1
+
+	items, snapshotID, err := getPlaylistItems(context.Background(), client, testPlaylistID)
+	if err != nil {
+		t.Fatalf("Expected the playlist to be read again, got %v", err)
+	}
+	if playlist.scans != 2 {
+		t.Errorf("Expected a second scan, got %d scans", playlist.scans)
+	}
+	if len(items) != 2 || snapshotID != playlist.snapshotID() {
+		t.Errorf("Expected 2 items at %q, got %d items at %q", playlist.snapshotID(), len(items), snapshotID)
+	}
+}
+
+func TestGetPlaylistItemsGivesUpOnPlaylistChangingConstantly(t *testing.T) {
+	playlist, client := newFakePlaylist(t, testTrackA)
+	playlist.changeDuringScans = maxConsistentReadAttempts
+
+	_, _, err := getPlaylistItems(context.Background(), client, testPlaylistID)
+	if err == nil || !strings.Contains(err.Error(), "kept changing") {
+		t.Errorf("Expected the read to give up, got %v", err)
+	}
+	if playlist.scans != maxConsistentReadAttempts {
+		t.Errorf("Expected %d scans, got %d", maxConsistentReadAttempts, playlist.scans)
+	}
+}
@@ -0,0 +1,68 @@
+package spotify
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestReconcilePlaylistItemsSplitsBatches(t *testing.T) {
+	current := testTrackURIs(0, 250)
+	playlist, client := newFakePlaylistOf(t, current)
+
+	// Keep every fifth item in reverse order, and add new items around them
+	var desired []string
+	desired = append(desired, testTrackURIs(1000, 70)...)
+	for i := len(current) - 1; i >= 0; i -= 5 {
+		desired = append(desired, current[i])
+	}
+	desired = append(desired, testTrackURIs(2000, 110)...)
+
+	snapshotID, err := reconcilePlaylistItems(context.Background(), client, testPlaylistID, desired)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := playlist.items(); !reflect.DeepEqual(got, desired) {
+		t.Errorf("Expected the playlist to hold the desired items, got %d items", len(got))
+	}
+	if snapshotID != playlist.snapshotID() {
+		t.Errorf("Expected the last snapshot ID %q, got %q", playlist.snapshotID(), snapshotID)
+	}
+
+	// 200 removals and 180 insertions take at least two requests each, the
+	// fake playlist fails the test for larger batches
+	if len(playlist.removals) < 2 || len(playlist.inserts) < 2 {
+		t.Errorf("Expected removals and inserts to be split into batches, got %d removals and %d inserts",
+			len(playlist.removals), len(playlist.inserts))
+	}
+}
+
+func TestReconcilePlaylistItemsKeepsUnavailableItems(t *testing.T) {
+	a, b, c := "spotify:track:"+testTrackA, "spotify:track:"+testTrackB, testTrackURIs(0, 1)[0]
+	playlist, client := newFakePlaylistOf(t, []string{a, "", b, ""})
+
+	if _, err := reconcilePlaylistItems(context.Background(), client, testPlaylistID, []string{c, b}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The unavailable items stay at their positions
+	expected := []string{c, "", b, ""}
+	if got := playlist.items(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	for _, removal := range playlist.removals {
+		for _, uri := range removal {
+			if uri != a {
+				t.Errorf("Expected only %s to be removed, got a removal of %q", a, uri)
+			}
+		}
+	}
+	for _, insert := range playlist.inserts {
+		for _, uri := range insert {
+			if uri != c {
+				t.Errorf("Expected only %s to be inserted, got an insert of %q", c, uri)
+			}
+		}
+	}
+}
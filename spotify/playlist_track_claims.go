@@ -0,0 +1,71 @@
+package spotify
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// trackClaims counts the reads of each occurrence of a track in a playlist
+// made by the spotify_playlist_track resources of one provider instance, so
+// resources claiming the same occurrence can be reported. Terraform
+// configures a new provider instance for every plan and every apply. A plan
+// reads each resource it refreshes once, and an apply reads each resource it
+// creates or updates once, so every read of an occurrence in an instance
+// comes from a different resource. Resource IDs cannot tell them apart:
+// resources claiming the same occurrence share their ID.
+//
+// Resources that are not read are not counted: a plan with -refresh=false
+// reports nothing, and neither do resources left out with -target. Creating a
+// resource for an occurrence another resource already manages fails instead,
+// see checkTrackInsert.
+type trackClaims struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// trackClaimKey identifies an occurrence of a track in a playlist
+func trackClaimKey(playlistID, trackID string, occurrence int) string {
+	return fmt.Sprintf("%s:%s:%d", playlistID, trackID, occurrence)
+}
+
+// claim records a resource reading key. Returns how many resources read it
+// before.
+func (c *trackClaims) claim(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts == nil {
+		c.counts = map[string]int{}
+	}
+	previous := c.counts[key]
+	c.counts[key]++
+	return previous
+}
+
+// playlistLocks serializes the edits spotify_playlist_track and
+// spotify_playlist_tracks resources make to a playlist, so the positions one
+// resource read are not shifted by another resource of the same apply before
+// it writes
+type playlistLocks struct {
+	mu    sync.Mutex
+	locks map[spotify.ID]*sync.Mutex
+}
+
+// lock locks the playlist and returns the function unlocking it
+func (l *playlistLocks) lock(playlistID spotify.ID) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = map[spotify.ID]*sync.Mutex{}
+	}
+	lock, ok := l.locks[playlistID]
+	if !ok {
+		lock = &sync.Mutex{}
+		l.locks[playlistID] = lock
+	}
+	l.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
@@ -3,14 +3,19 @@ package spotify
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"github.com/zmb3/spotify/v2"
 	"golang.org/x/oauth2"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/logging"
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/scopes"
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/transport"
 )
 
 func Provider() *schema.Provider {
@@ -24,20 +29,53 @@ func Provider() *schema.Provider {
 			},
 			"client_secret": {
 				Type:        schema.TypeString,
-				Required:    true,
+				Optional:    true,
 				Sensitive:   true,
-				Description: "The client secret for Spotify API authentication",
+				Description: "The client secret for Spotify API authentication. Required for the refresh_token and client_credentials auth modes",
 			},
 			"redirect_uri": {
 				Type:        schema.TypeString,
-				Required:    true,
+				Optional:    true,
 				Description: "The redirect URI for Spotify API authentication",
 			},
 			"refresh_token": {
 				Type:        schema.TypeString,
-				Required:    true,
+				Optional:    true,
 				Sensitive:   true,
-				Description: "The refresh token for Spotify API",
+				Description: "The refresh token for Spotify API. Required for the refresh_token and pkce auth modes",
+			},
+			"token_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("SPOTIFY_TOKEN_FILE", nil),
+				ConflictsWith: []string{"credential_process"},
+				Description:   "Path to a JSON token file, as written by spotify_auth_proxy. Rotated tokens are written back to it. Can also be set with the SPOTIFY_TOKEN_FILE environment variable",
+			},
+			"credential_process": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"token_file"},
+				Description:   "Command that prints a JSON token with an access_token on stdout. It runs whenever the previous token has expired",
+			},
+			"auth_mode": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      authModeRefreshToken,
+				ValidateFunc: validation.StringInSlice([]string{authModeRefreshToken, authModeClientCredentials, authModePKCE}, false),
+				Description:  "How the provider authenticates: refresh_token (user login with a client secret), client_credentials (app-only, no user data) or pkce (user login for public clients without a client secret)",
+			},
+			"validation_mode": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      validationModeBasic,
+				ValidateFunc: validation.StringInSlice([]string{validationModeNone, validationModeBasic, validationModeDeep}, false),
+				Description:  "How the provider checks its credentials at configure time: none, basic (a single current user lookup) or deep (reports the availability of each Spotify endpoint the provider uses)",
+			},
+			"skip_credentials_validation": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Skip the credentials check at configure time. Equivalent to validation_mode = \"none\"",
 			},
 			"weather_api_key": {
 				Type:        schema.TypeString,
@@ -45,11 +83,40 @@ func Provider() *schema.Provider {
 				Sensitive:   true,
 				Description: "API key for OpenWeatherMap",
 			},
+			"api_base_url": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("SPOTIFY_API_BASE_URL", defaultAPIBaseURL),
+				ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+				Description:  "Base URL of the Spotify Web API. Can also be set with the SPOTIFY_API_BASE_URL environment variable",
+			},
+			"accounts_base_url": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("SPOTIFY_ACCOUNTS_BASE_URL", defaultAccountsBaseURL),
+				ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+				Description:  "Base URL of the Spotify Accounts service used for OAuth. Can also be set with the SPOTIFY_ACCOUNTS_BASE_URL environment variable",
+			},
+			"max_retries": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      transport.DefaultMaxRetries,
+				ValidateFunc: validation.IntBetween(0, 20),
+				Description:  "Maximum number of retries for a request that was rate limited (HTTP 429) or failed with a server error",
+			},
+			"max_retry_wait": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      int(transport.DefaultMaxWait / time.Second),
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Maximum number of seconds to wait before a single retry. Rate limits with a longer Retry-After fail immediately",
+			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"spotify_playlist":       resourceSpotifyPlaylist(),
-			"spotify_playlist_track": resourceSpotifyPlaylistTrack(),
-			"spotify_playlist_cover": resourceSpotifyPlaylistCover(),
+			"spotify_playlist":        resourceSpotifyPlaylist(),
+			"spotify_playlist_track":  resourceSpotifyPlaylistTrack(),
+			"spotify_playlist_tracks": resourceSpotifyPlaylistTracks(),
+			"spotify_playlist_cover":  resourceSpotifyPlaylistCover(),
 		},
 		DataSourcesMap: map[string]*schema.Resource{
 			"spotify_tracks":             dataSourceSpotifyTracks(),
@@ -64,116 +131,130 @@ func Provider() *schema.Provider {
 	}
 }
 
+const (
+	// defaultAPIBaseURL is the public Spotify Web API endpoint
+	defaultAPIBaseURL = "https://api.spotify.com"
+	// defaultAccountsBaseURL is the public Spotify Accounts service endpoint
+	defaultAccountsBaseURL = "https://accounts.spotify.com"
+)
+
 // ProviderClient holds the Spotify client and other API clients
 type ProviderClient struct {
 	SpotifyClient *spotify.Client
-	WeatherAPIKey string
+	// HTTPClient is the authenticated, rate-limit-aware client for direct API calls
+	HTTPClient *http.Client
+	// TokenSource is the shared, concurrency-safe source of access tokens
+	TokenSource oauth2.TokenSource
+	// AuthMode is the configured authentication mode, see auth_mode
+	AuthMode string
+	// GrantedScopes are the scopes granted to the token, nil when unknown
+	GrantedScopes   []string
+	WeatherAPIKey   string
+	APIBaseURL      string
+	AccountsBaseURL string
+
+	// trackClaims detects spotify_playlist_track resources managing the same track occurrence
+	trackClaims trackClaims
+	// playlistLocks serializes the edits of spotify_playlist_track and spotify_playlist_tracks resources per playlist
+	playlistLocks playlistLocks
+	// playlistItems shares playlist scans between resources, keyed by snapshot ID
+	playlistItems playlistItemsCache
+}
+
+// apiURL builds a Spotify Web API URL for the given path, e.g. "playlists/123/images"
+func (c *ProviderClient) apiURL(path string) string {
+	return fmt.Sprintf("%s/v1/%s", c.APIBaseURL, strings.TrimPrefix(path, "/"))
+}
+
+// oauthEndpoint returns the OAuth2 endpoint served by the given accounts base URL
+func oauthEndpoint(accountsBaseURL string) oauth2.Endpoint {
+	return oauth2.Endpoint{
+		AuthURL:  accountsBaseURL + "/authorize",
+		TokenURL: accountsBaseURL + "/api/token",
+	}
 }
 
 func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
 	var diags diag.Diagnostics
+	logger := logging.DefaultLogger.WithContext(ctx)
 
 	clientID := d.Get("client_id").(string)
 	clientSecret := d.Get("client_secret").(string)
 	redirectURI := d.Get("redirect_uri").(string)
 	refreshToken := d.Get("refresh_token").(string)
+	authMode := d.Get("auth_mode").(string)
+	tokenFile := d.Get("token_file").(string)
+	credentialProcess := d.Get("credential_process").(string)
+	validationMode := d.Get("validation_mode").(string)
+	skipValidation := d.Get("skip_credentials_validation").(bool)
 	weatherAPIKey := d.Get("weather_api_key").(string)
+	apiBaseURL := strings.TrimSuffix(d.Get("api_base_url").(string), "/")
+	accountsBaseURL := strings.TrimSuffix(d.Get("accounts_base_url").(string), "/")
+	maxRetries := d.Get("max_retries").(int)
+	maxRetryWait := time.Duration(d.Get("max_retry_wait").(int)) * time.Second
 
-	oauthConfig := &oauth2.Config{
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		RedirectURL:  redirectURI,
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  "https://accounts.spotify.com/authorize",
-			TokenURL: "https://accounts.spotify.com/api/token",
-		},
-		Scopes: []string{
-			"user-read-private",
-			"playlist-modify-public",
-			"playlist-modify-private",
-			"user-top-read",
-			"user-read-recently-played",
-			"ugc-image-upload",
-		},
-	}
+	// Make sure none of the configured credentials can end up in the logs
+	logging.RegisterSecret(clientSecret)
+	logging.RegisterSecret(refreshToken)
+	logging.RegisterSecret(weatherAPIKey)
 
-	// Use refresh token to obtain a new access token
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	tokenSource := oauthConfig.TokenSource(ctx, token)
-	newToken, err := tokenSource.Token()
-	if err != nil {
-		return nil, diag.FromErr(fmt.Errorf("error refreshing access token: %s", err))
+	// Every request, including token refreshes, goes through the retrying transport
+	retryingClient := &http.Client{
+		Transport: transport.NewRetryTransport(http.DefaultTransport, maxRetries, maxRetryWait),
 	}
 
-	// Print token information for debugging
-	fmt.Printf("Access Token: %s...\n", newToken.AccessToken[:10])
-	fmt.Printf("Token Type: %s\n", newToken.TokenType)
-	fmt.Printf("Token Expiry: %s\n", newToken.Expiry.String())
+	// The token source outlives this call, so it must not be bound to the
+	// configure request context, which is cancelled once configuration ends
+	authCtx := context.WithValue(context.Background(), oauth2.HTTPClient, retryingClient)
 
-	// Set credentials as environment variables for other resources to use
-	// This is a workaround for resources that need to make direct API calls
-	if err := os.Setenv("SPOTIFY_ACCESS_TOKEN", newToken.AccessToken); err != nil {
-		return nil, diag.FromErr(fmt.Errorf("error setting SPOTIFY_ACCESS_TOKEN environment variable: %s", err))
+	tokenSource, diags := newAuthTokenSource(authCtx, authConfig{
+		Mode:              authMode,
+		ClientID:          clientID,
+		ClientSecret:      clientSecret,
+		RedirectURI:       redirectURI,
+		RefreshToken:      refreshToken,
+		TokenFile:         tokenFile,
+		CredentialProcess: credentialProcess,
+		AccountsBaseURL:   accountsBaseURL,
+	})
+	if diags.HasError() {
+		return nil, diags
 	}
-	if err := os.Setenv("SPOTIFY_REFRESH_TOKEN", refreshToken); err != nil {
-		return nil, diag.FromErr(fmt.Errorf("error setting SPOTIFY_REFRESH_TOKEN environment variable: %s", err))
-	}
-	if err := os.Setenv("SPOTIFY_CLIENT_ID", clientID); err != nil {
-		return nil, diag.FromErr(fmt.Errorf("error setting SPOTIFY_CLIENT_ID environment variable: %s", err))
-	}
-	if err := os.Setenv("SPOTIFY_CLIENT_SECRET", clientSecret); err != nil {
-		return nil, diag.FromErr(fmt.Errorf("error setting SPOTIFY_CLIENT_SECRET environment variable: %s", err))
-	}
-	if err := os.Setenv("SPOTIFY_REDIRECT_URI", redirectURI); err != nil {
-		return nil, diag.FromErr(fmt.Errorf("error setting SPOTIFY_REDIRECT_URI environment variable: %s", err))
+
+	newToken, err := tokenSource.Token()
+	if err != nil {
+		return nil, diag.FromErr(fmt.Errorf("error obtaining access token using the %s auth mode: %s", authMode, err))
 	}
+	logger.Debug("Obtained Spotify access token", "token_type", newToken.TokenType, "expiry", newToken.Expiry.String())
 
-	httpClient := oauthConfig.Client(ctx, newToken)
-	spotifyClient := spotify.New(httpClient)
+	httpClient := oauth2.NewClient(authCtx, tokenSource)
+	spotifyClient := spotify.New(httpClient, spotify.WithBaseURL(apiBaseURL+"/v1/"))
 
-	// Test with explicit API call format
-	req, err := http.NewRequest("GET", "https://api.spotify.com/v1/recommendations?seed_genres=pop&limit=1", nil)
-	if err != nil {
-		fmt.Printf("Error creating request: %s\n", err)
-	} else {
-		req.Header.Set("Authorization", "Bearer "+newToken.AccessToken)
-		resp, httpErr := httpClient.Do(req)
-		if httpErr != nil {
-			fmt.Printf("Error making direct API call: %s\n", httpErr)
-		} else {
-			fmt.Printf("Direct API call status: %d\n", resp.StatusCode)
-			body, err := io.ReadAll(resp.Body)
-			if err != nil {
-				fmt.Printf("Error reading response body: %s\n", err)
-			} else {
-				fmt.Printf("Response body: %s\n", string(body))
-			}
-			if err := resp.Body.Close(); err != nil {
-				fmt.Printf("Error closing response body: %s\n", err)
-			}
-		}
+	providerClient := &ProviderClient{
+		SpotifyClient:   spotifyClient,
+		HTTPClient:      httpClient,
+		TokenSource:     tokenSource,
+		AuthMode:        authMode,
+		WeatherAPIKey:   weatherAPIKey,
+		APIBaseURL:      apiBaseURL,
+		AccountsBaseURL: accountsBaseURL,
 	}
 
-	// Test API connection
-	user, err := spotifyClient.CurrentUser(ctx)
-	if err != nil {
-		return nil, diag.FromErr(fmt.Errorf("error authenticating with Spotify: %s", err))
+	// Record the scopes actually granted, as reported in the token response
+	if scope, ok := newToken.Extra("scope").(string); ok && authMode != authModeClientCredentials {
+		providerClient.GrantedScopes = scopes.Parse(scope)
+		logger.Debug("Spotify token scopes", "scopes", scope)
+		diags = append(diags, scopeWarnings(providerClient.GrantedScopes)...)
 	}
-	fmt.Printf("Logged in as: %s\n", user.ID)
 
-	// Test recommendations API with a simple query
-	fmt.Println("Testing recommendations API...")
-	seeds := spotify.Seeds{Genres: []string{"pop"}}
-	attrs := spotify.NewTrackAttributes()
-	recs, err := spotifyClient.GetRecommendations(ctx, seeds, attrs, spotify.Limit(1))
-	if err != nil {
-		fmt.Printf("Warning: Recommendations API test failed: %s\n", err)
-	} else {
-		fmt.Printf("Recommendations API test successful, got %d tracks\n", len(recs.Tracks))
+	// Check the credentials against the API, as configured
+	if skipValidation {
+		validationMode = validationModeNone
+	}
+	diags = append(diags, validateCredentials(ctx, providerClient, validationMode)...)
+	if diags.HasError() {
+		return nil, diags
 	}
 
-	return &ProviderClient{
-		SpotifyClient: spotifyClient,
-		WeatherAPIKey: weatherAPIKey,
-	}, diags
+	return providerClient, diags
 }
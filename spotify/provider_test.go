@@ -0,0 +1,183 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	ctyjson "github.com/hashicorp/go-cty/cty/json"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/scopes"
+)
+
+// fakeAccessToken is the access token handed out by fakeSpotifyServer
+const fakeAccessToken = "fake-access-token"
+
+// fakeSpotifyServer serves the token endpoint of the Spotify Accounts service
+// and answers the API requests made while the provider is configured
+type fakeSpotifyServer struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// tokenRequests holds the form of every token request, with the client ID
+	// of requests authenticated with HTTP basic auth as "basic_client_id"
+	tokenRequests []url.Values
+	// apiPaths holds the path of every API request below /v1/
+	apiPaths []string
+	// status overrides the status of API paths, e.g. "browse/new-releases"
+	status map[string]int
+	// scope is returned as the granted scopes of every token, all of the
+	// scopes the provider uses by default
+	scope string
+}
+
+func newFakeSpotifyServer(t *testing.T) *fakeSpotifyServer {
+	t.Helper()
+
+	f := &fakeSpotifyServer{status: map[string]int{}, scope: strings.Join(scopes.All(), " ")}
+	f.Server = httptest.NewServer(f)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeSpotifyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/token":
+		r.ParseForm()
+		form := r.PostForm
+		if clientID, _, ok := r.BasicAuth(); ok {
+			form.Set("basic_client_id", clientID)
+		}
+		f.tokenRequests = append(f.tokenRequests, form)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fakeAccessToken,
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"scope":        f.scope,
+		})
+
+	case strings.HasPrefix(r.URL.Path, "/v1/"):
+		path := strings.TrimPrefix(r.URL.Path, "/v1/")
+		f.apiPaths = append(f.apiPaths, path)
+
+		if r.Header.Get("Authorization") != "Bearer "+fakeAccessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"status": 401, "message": "Invalid access token"}})
+			return
+		}
+		if status := f.status[path]; status != 0 {
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"status": status, "message": http.StatusText(status)}})
+			return
+		}
+		if path == "me" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "fake-user"})
+			return
+		}
+		w.Write([]byte("{}"))
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// requests returns the token requests and API paths received so far
+func (f *fakeSpotifyServer) requests() ([]url.Values, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]url.Values(nil), f.tokenRequests...), append([]string(nil), f.apiPaths...)
+}
+
+// configureProvider configures the provider with the given arguments, which
+// are not read from the environment
+func configureProvider(t *testing.T, raw map[string]interface{}) (*ProviderClient, diag.Diagnostics) {
+	t.Helper()
+
+	for _, env := range []string{"SPOTIFY_TOKEN_FILE", "SPOTIFY_API_BASE_URL", "SPOTIFY_ACCOUNTS_BASE_URL"} {
+		t.Setenv(env, "")
+	}
+
+	d := schema.TestResourceDataRaw(t, Provider().Schema, raw)
+	meta, diags := providerConfigure(context.Background(), d)
+	client, _ := meta.(*ProviderClient)
+	return client, diags
+}
+
+// planResource plans the configuration of a resource against its state, nil
+// for a new resource. Like Terraform, it passes the configuration on as the
+// raw config too, which CustomizeDiff and, through Apply, Create can read.
+func planResource(t *testing.T, r *schema.Resource, state *terraform.InstanceState, raw map[string]interface{}, m interface{}) (*terraform.InstanceState, *terraform.InstanceDiff) {
+	t.Helper()
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		t.Fatalf("Error encoding the configuration: %v", err)
+	}
+	block := r.CoreConfigSchema()
+	config, err := ctyjson.Unmarshal(data, block.ImpliedType())
+	if err != nil {
+		t.Fatalf("Error decoding the configuration: %v", err)
+	}
+
+	if state == nil {
+		state = &terraform.InstanceState{RawState: cty.NullVal(block.ImpliedType())}
+	}
+	state = state.DeepCopy()
+	state.RawConfig = config
+
+	diff, err := r.SimpleDiff(context.Background(), state, terraform.NewResourceConfigShimmed(config, block), m)
+	if err != nil {
+		t.Fatalf("Error planning the resource: %v", err)
+	}
+	diff.RawConfig = config
+	return state, diff
+}
+
+func TestProviderConfigureUsesBaseURLs(t *testing.T) {
+	server := newFakeSpotifyServer(t)
+
+	client, diags := configureProvider(t, map[string]interface{}{
+		"client_id":         "client",
+		"client_secret":     "secret",
+		"refresh_token":     "refresh",
+		"api_base_url":      server.URL + "/",
+		"accounts_base_url": server.URL + "/",
+	})
+	if diags.HasError() {
+		t.Fatalf("Expected the provider to be configured, got %v", diags)
+	}
+
+	if client.APIBaseURL != server.URL || client.AccountsBaseURL != server.URL {
+		t.Errorf("Expected trailing slashes to be trimmed, got %q and %q", client.APIBaseURL, client.AccountsBaseURL)
+	}
+	if got := client.apiURL("playlists/abc/images"); got != server.URL+"/v1/playlists/abc/images" {
+		t.Errorf("Expected apiURL to use the API base URL, got %q", got)
+	}
+	if got := oauthEndpoint(client.AccountsBaseURL).TokenURL; got != server.URL+"/api/token" {
+		t.Errorf("Expected the token URL to use the accounts base URL, got %q", got)
+	}
+
+	// The token was obtained from, and the credentials validated against, the fake server
+	tokenRequests, apiPaths := server.requests()
+	if len(tokenRequests) != 1 {
+		t.Errorf("Expected one token request, got %d", len(tokenRequests))
+	}
+	if len(apiPaths) != 1 || apiPaths[0] != "me" {
+		t.Errorf("Expected the current user to be looked up, got %v", apiPaths)
+	}
+}
@@ -0,0 +1,75 @@
+package reconcile
+
+// Additive returns the list current becomes when only some of its items are
+// managed: previous is the list managed so far and desired the list to manage
+// from now on. As in Plan, duplicates are matched by occurrence, and the
+// managed occurrences of a URI are its first ones in current.
+//
+// Managed occurrences that are no longer desired are dropped. Desired items
+// that current holds fewer occurrences of are appended, in desired order.
+// Every other item keeps its place, so the result never requires moves.
+func Additive(current, previous, desired []string) []string {
+	previousCounts := countOccurrences(previous)
+	desiredCounts := countOccurrences(desired)
+
+	result := make([]string, 0, len(current)+len(desired))
+	kept := map[string]int{}
+	seen := map[string]int{}
+	for _, uri := range current {
+		n := seen[uri]
+		seen[uri]++
+
+		// Occurrences between the desired and previous counts are managed but no longer wanted
+		if n >= desiredCounts[uri] && n < previousCounts[uri] {
+			continue
+		}
+		result = append(result, uri)
+		kept[uri]++
+	}
+
+	wanted := map[string]int{}
+	for _, uri := range desired {
+		n := wanted[uri]
+		wanted[uri]++
+
+		if n >= kept[uri] {
+			result = append(result, uri)
+		}
+	}
+
+	return result
+}
+
+// Match returns, for each item of managed, the position in current of the
+// occurrence it accounts for: the n-th occurrence of a URI in managed is
+// matched with the n-th one in current. Items current holds too few
+// occurrences of are matched with -1.
+func Match(current, managed []string) []int {
+	positions := map[string][]int{}
+	for i, uri := range current {
+		positions[uri] = append(positions[uri], i)
+	}
+
+	result := make([]int, len(managed))
+	seen := map[string]int{}
+	for i, uri := range managed {
+		n := seen[uri]
+		seen[uri]++
+
+		result[i] = -1
+		if n < len(positions[uri]) {
+			result[i] = positions[uri][n]
+		}
+	}
+
+	return result
+}
+
+// countOccurrences returns how often each URI occurs in list
+func countOccurrences(list []string) map[string]int {
+	counts := make(map[string]int, len(list))
+	for _, uri := range list {
+		counts[uri]++
+	}
+	return counts
+}
@@ -0,0 +1,57 @@
+package reconcile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAdditiveLeavesOtherItems(t *testing.T) {
+	current := uris("x", "a", "y", "b")
+	previous := uris("a", "b")
+	desired := uris("b", "c")
+
+	expected := uris("x", "y", "b", "c")
+	got := Additive(current, previous, desired)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+
+	// Only removals and inserts are needed
+	for _, step := range Plan(current, got) {
+		if step.Op == Move {
+			t.Errorf("Expected no moves, got %+v", step)
+		}
+	}
+}
+
+func TestAdditiveDuplicates(t *testing.T) {
+	current := uris("a", "x", "a", "a")
+
+	// The first two occurrences of a are managed, the third is not
+	got := Additive(current, uris("a", "a"), uris("a"))
+	if expected := uris("a", "x", "a"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	// Existing occurrences count towards the desired ones
+	got = Additive(uris("a", "x"), nil, uris("a", "a"))
+	if expected := uris("a", "x", "a"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestAdditiveRestoresMissingItems(t *testing.T) {
+	managed := uris("a", "b")
+	got := Additive(uris("x", "b"), managed, managed)
+	if expected := uris("x", "b", "a"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	current := uris("a", "x", "a", "b")
+	got := Match(current, uris("b", "a", "a", "a", "c"))
+	if expected := []int{3, 0, 2, -1, -1}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,39 @@
+package reconcile
+
+import "sort"
+
+// Pin returns desired with the items of current at the given positions
+// inserted at those same positions, so a Plan towards the result neither
+// removes nor inserts them. This keeps items the Spotify Web API cannot
+// identify, such as unavailable tracks, where they are. Pinned items must be
+// unique; those desired already holds are not added again, and positions past
+// the end of the result are appended in order.
+func Pin(current, desired []string, positions []int) []string {
+	if len(positions) == 0 {
+		return desired
+	}
+
+	held := make(map[string]bool, len(desired))
+	for _, uri := range desired {
+		held[uri] = true
+	}
+
+	var pinned []int
+	for _, position := range positions {
+		if !held[current[position]] {
+			pinned = append(pinned, position)
+		}
+	}
+	sort.Ints(pinned)
+
+	result := make([]string, 0, len(desired)+len(pinned))
+	next := 0
+	for _, position := range pinned {
+		for len(result) < position && next < len(desired) {
+			result = append(result, desired[next])
+			next++
+		}
+		result = append(result, current[position])
+	}
+	return append(result, desired[next:]...)
+}
@@ -0,0 +1,53 @@
+package reconcile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPinKeepsItemsInPlace(t *testing.T) {
+	current := []string{"spotify:track:a", "unavailable:1", "spotify:track:b", "unavailable:3"}
+	desired := uris("b", "c", "d")
+
+	expected := []string{"spotify:track:b", "unavailable:1", "spotify:track:c", "unavailable:3", "spotify:track:d"}
+	got := Pin(current, desired, []int{1, 3})
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+
+	// The pinned items are neither removed nor inserted
+	for _, step := range Plan(current, got) {
+		for _, removal := range step.Removals {
+			if removal.URI == "unavailable:1" || removal.URI == "unavailable:3" {
+				t.Errorf("Expected pinned items to be kept, got %+v", step)
+			}
+		}
+		for _, uri := range step.URIs {
+			if uri == "unavailable:1" || uri == "unavailable:3" {
+				t.Errorf("Expected pinned items not to be inserted, got %+v", step)
+			}
+		}
+	}
+	if result := apply(t, current, Plan(current, got)); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
+
+func TestPinAppendsPastTheEnd(t *testing.T) {
+	current := []string{"spotify:track:a", "spotify:track:b", "unavailable:2"}
+
+	expected := []string{"unavailable:2"}
+	if got := Pin(current, nil, []int{2}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestPinSkipsHeldItems(t *testing.T) {
+	current := []string{"unavailable:0", "spotify:track:a"}
+
+	// Additive results already hold the items they leave alone
+	desired := Additive(current, uris("a"), uris("b"))
+	if got := Pin(current, desired, []int{0}); !reflect.DeepEqual(got, desired) {
+		t.Errorf("Expected %v, got %v", desired, got)
+	}
+}
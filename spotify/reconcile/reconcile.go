@@ -0,0 +1,229 @@
+// Package reconcile computes the playlist edits that turn the current list of
+// items into the desired one. Plans are made of removals, moves and inserts
+// that the Spotify Web API can apply one request at a time, so existing items
+// keep their added_at history and playlists of any length can be edited.
+package reconcile
+
+import "sort"
+
+// MaxBatchSize is the maximum number of items a single Spotify add or remove request accepts
+const MaxBatchSize = 100
+
+// Operation is the kind of a plan step
+type Operation int
+
+const (
+	// Remove deletes items at the given positions
+	Remove Operation = iota
+
+	// Move reorders a range of items
+	Move
+
+	// Insert adds items at a position
+	Insert
+)
+
+// String implements fmt.Stringer
+func (o Operation) String() string {
+	switch o {
+	case Remove:
+		return "remove"
+	case Move:
+		return "move"
+	case Insert:
+		return "insert"
+	}
+	return "unknown"
+}
+
+// Removal identifies the occurrences of an item to remove
+type Removal struct {
+	URI       string
+	Positions []int
+}
+
+// Step is a single playlist edit. Positions refer to the playlist as left by
+// the previous step, so steps must be applied in order.
+type Step struct {
+	Op Operation
+
+	// Removals is set for Remove steps
+	Removals []Removal
+
+	// RangeStart, RangeLength and InsertBefore are set for Move steps, with
+	// the meaning of the Spotify reorder endpoint
+	RangeStart   int
+	RangeLength  int
+	InsertBefore int
+
+	// Position and URIs are set for Insert steps
+	Position int
+	URIs     []string
+}
+
+// Plan returns the steps that turn current into desired. Items are compared
+// by URI; duplicates are matched by occurrence, so the n-th occurrence in
+// current is kept as the n-th occurrence in desired.
+//
+// Items only in current are removed, in batches from the end of the playlist
+// so earlier positions stay valid. The remaining items are reordered with as
+// few moves as possible, keeping the longest run that is already in the
+// desired order. Items only in desired are then inserted in batches in
+// ascending position order.
+func Plan(current, desired []string) []Step {
+	// Match occurrences of each URI between current and desired
+	desiredPositions := map[string][]int{}
+	for i, uri := range desired {
+		desiredPositions[uri] = append(desiredPositions[uri], i)
+	}
+
+	matchedDesired := make([]bool, len(desired))
+	var removed []int
+	var kept []int // desired index of each kept item, in current order
+	for i, uri := range current {
+		positions := desiredPositions[uri]
+		if len(positions) == 0 {
+			removed = append(removed, i)
+			continue
+		}
+		kept = append(kept, positions[0])
+		matchedDesired[positions[0]] = true
+		desiredPositions[uri] = positions[1:]
+	}
+
+	var steps []Step
+	steps = append(steps, planRemovals(current, removed)...)
+	steps = append(steps, planMoves(kept)...)
+	steps = append(steps, planInserts(desired, matchedDesired)...)
+	return steps
+}
+
+// planRemovals batches the removals of the given positions, highest first
+func planRemovals(current []string, positions []int) []Step {
+	sort.Sort(sort.Reverse(sort.IntSlice(positions)))
+
+	var steps []Step
+	for start := 0; start < len(positions); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(positions) {
+			end = len(positions)
+		}
+
+		// Group the positions of each URI, keeping the first-seen order
+		var removals []Removal
+		index := map[string]int{}
+		for _, position := range positions[start:end] {
+			uri := current[position]
+			i, ok := index[uri]
+			if !ok {
+				i = len(removals)
+				index[uri] = i
+				removals = append(removals, Removal{URI: uri})
+			}
+			removals[i].Positions = append(removals[i].Positions, position)
+		}
+
+		steps = append(steps, Step{Op: Remove, Removals: removals})
+	}
+	return steps
+}
+
+// planMoves returns the moves that sort a list holding the given desired
+// indexes. Items on a longest increasing subsequence stay in place, every
+// other item is moved once, in ascending desired order, right after the
+// closest item that is already in place before it.
+func planMoves(list []int) []Step {
+	placed := map[int]bool{}
+	for _, i := range longestIncreasingSubsequence(list) {
+		placed[list[i]] = true
+	}
+
+	var toMove []int
+	for _, value := range list {
+		if !placed[value] {
+			toMove = append(toMove, value)
+		}
+	}
+	sort.Ints(toMove)
+
+	list = append([]int(nil), list...)
+	var steps []Step
+	for _, value := range toMove {
+		from, insertBefore := -1, 0
+		for i, v := range list {
+			if v == value {
+				from = i
+			} else if placed[v] && v < value {
+				insertBefore = i + 1
+			}
+		}
+
+		if from != insertBefore && from+1 != insertBefore {
+			steps = append(steps, Step{Op: Move, RangeStart: from, RangeLength: 1, InsertBefore: insertBefore})
+			list = moveItem(list, from, insertBefore)
+		}
+		placed[value] = true
+	}
+	return steps
+}
+
+// planInserts batches the desired items without a match into inserts of
+// consecutive positions
+func planInserts(desired []string, matched []bool) []Step {
+	var steps []Step
+	for i := 0; i < len(desired); i++ {
+		if matched[i] {
+			continue
+		}
+
+		step := Step{Op: Insert, Position: i}
+		for ; i < len(desired) && !matched[i] && len(step.URIs) < MaxBatchSize; i++ {
+			step.URIs = append(step.URIs, desired[i])
+		}
+		i--
+
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// moveItem moves the item at from so it ends up before the item that was at insertBefore
+func moveItem(list []int, from, insertBefore int) []int {
+	value := list[from]
+	list = append(list[:from], list[from+1:]...)
+	if insertBefore > from {
+		insertBefore--
+	}
+	list = append(list[:insertBefore], append([]int{value}, list[insertBefore:]...)...)
+	return list
+}
+
+// longestIncreasingSubsequence returns the indexes of a longest strictly
+// increasing subsequence of values, in O(n log n)
+func longestIncreasingSubsequence(values []int) []int {
+	var tails []int // index of the smallest tail of each subsequence length
+	previous := make([]int, len(values))
+
+	for i, value := range values {
+		length := sort.Search(len(tails), func(j int) bool { return values[tails[j]] >= value })
+		if length > 0 {
+			previous[i] = tails[length-1]
+		} else {
+			previous[i] = -1
+		}
+		if length == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[length] = i
+		}
+	}
+
+	result := make([]int, len(tails))
+	if len(tails) == 0 {
+		return result
+	}
+	for i, k := len(tails)-1, tails[len(tails)-1]; i >= 0; i, k = i-1, previous[k] {
+		result[i] = k
+	}
+	return result
+}
@@ -0,0 +1,167 @@
+package reconcile
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// apply runs the steps against list the way the Spotify API would
+func apply(t *testing.T, list []string, steps []Step) []string {
+	t.Helper()
+	list = append([]string(nil), list...)
+
+	for _, step := range steps {
+		switch step.Op {
+		case Remove:
+			remove := map[int]bool{}
+			count := 0
+			for _, removal := range step.Removals {
+				for _, position := range removal.Positions {
+					if position >= len(list) || list[position] != removal.URI {
+						t.Fatalf("Removal of %s at %d does not match %v", removal.URI, position, list)
+					}
+					remove[position] = true
+					count++
+				}
+			}
+			if count > MaxBatchSize {
+				t.Fatalf("Removal batch of %d items exceeds %d", count, MaxBatchSize)
+			}
+
+			var next []string
+			for i, uri := range list {
+				if !remove[i] {
+					next = append(next, uri)
+				}
+			}
+			list = next
+
+		case Move:
+			if step.RangeStart < 0 || step.RangeStart >= len(list) || step.InsertBefore < 0 || step.InsertBefore > len(list) {
+				t.Fatalf("Move %+v out of range for %d items", step, len(list))
+			}
+			item := list[step.RangeStart]
+			list = append(list[:step.RangeStart:step.RangeStart], list[step.RangeStart+1:]...)
+			insertBefore := step.InsertBefore
+			if insertBefore > step.RangeStart {
+				insertBefore--
+			}
+			list = append(list[:insertBefore:insertBefore], append([]string{item}, list[insertBefore:]...)...)
+
+		case Insert:
+			if len(step.URIs) == 0 || len(step.URIs) > MaxBatchSize || step.Position > len(list) {
+				t.Fatalf("Invalid insert %+v for %d items", step, len(list))
+			}
+			list = append(list[:step.Position:step.Position], append(append([]string(nil), step.URIs...), list[step.Position:]...)...)
+		}
+	}
+
+	return list
+}
+
+func uris(names ...string) []string {
+	result := make([]string, len(names))
+	for i, name := range names {
+		result[i] = "spotify:track:" + name
+	}
+	return result
+}
+
+func TestPlanNoChanges(t *testing.T) {
+	list := uris("a", "b", "c")
+	if steps := Plan(list, list); len(steps) != 0 {
+		t.Errorf("Expected no steps, got %+v", steps)
+	}
+}
+
+func TestPlanKeepsExistingItems(t *testing.T) {
+	current := uris("a", "b", "c", "d")
+	desired := uris("a", "x", "c", "d", "y")
+
+	steps := Plan(current, desired)
+	if got := apply(t, current, steps); !reflect.DeepEqual(got, desired) {
+		t.Fatalf("Expected %v, got %v", desired, got)
+	}
+
+	// Only b is removed and x and y are inserted, a, c and d are untouched
+	var ops []Operation
+	for _, step := range steps {
+		ops = append(ops, step.Op)
+	}
+	if !reflect.DeepEqual(ops, []Operation{Remove, Insert, Insert}) {
+		t.Errorf("Expected one removal and two inserts, got %v", ops)
+	}
+}
+
+func TestPlanMinimalMoves(t *testing.T) {
+	current := uris("a", "b", "c", "d", "e")
+	desired := uris("e", "a", "b", "c", "d")
+
+	steps := Plan(current, desired)
+	if len(steps) != 1 || steps[0].Op != Move {
+		t.Fatalf("Expected a single move, got %+v", steps)
+	}
+	if got := apply(t, current, steps); !reflect.DeepEqual(got, desired) {
+		t.Errorf("Expected %v, got %v", desired, got)
+	}
+}
+
+func TestPlanDuplicates(t *testing.T) {
+	current := uris("a", "b", "a", "c", "a")
+	desired := uris("a", "c", "a")
+
+	steps := Plan(current, desired)
+	if got := apply(t, current, steps); !reflect.DeepEqual(got, desired) {
+		t.Fatalf("Expected %v, got %v", desired, got)
+	}
+}
+
+func TestPlanBatchesLargePlaylists(t *testing.T) {
+	var current, desired []string
+	for i := 0; i < 2500; i++ {
+		current = append(current, fmt.Sprintf("spotify:track:old%d", i))
+		desired = append(desired, fmt.Sprintf("spotify:track:new%d", i))
+	}
+
+	steps := Plan(current, desired)
+	if len(steps) != 50 {
+		t.Errorf("Expected 25 removal and 25 insert batches, got %d steps", len(steps))
+	}
+	if got := apply(t, current, steps); !reflect.DeepEqual(got, desired) {
+		t.Error("Expected the plan to produce the desired playlist")
+	}
+}
+
+func TestPlanRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for n := 0; n < 200; n++ {
+		current := make([]string, rng.Intn(300))
+		for i := range current {
+			current[i] = fmt.Sprintf("spotify:track:%d", rng.Intn(150))
+		}
+		desired := make([]string, rng.Intn(300))
+		for i := range desired {
+			desired[i] = fmt.Sprintf("spotify:track:%d", rng.Intn(150))
+		}
+
+		if got := apply(t, current, Plan(current, desired)); !reflect.DeepEqual(got, desired) && len(got)+len(desired) > 0 {
+			t.Fatalf("Plan(%v, %v) produced %v", current, desired, got)
+		}
+	}
+}
+
+func TestLongestIncreasingSubsequence(t *testing.T) {
+	values := []int{3, 0, 4, 1, 2, 5}
+	got := longestIncreasingSubsequence(values)
+
+	var lis []int
+	for _, i := range got {
+		lis = append(lis, values[i])
+	}
+	if !reflect.DeepEqual(lis, []int{0, 1, 2, 5}) {
+		t.Errorf("Expected [0 1 2 5], got %v", lis)
+	}
+}
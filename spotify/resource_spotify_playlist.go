@@ -1,25 +1,47 @@
 package spotify
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"github.com/zmb3/spotify/v2"
 
 	// "github.com/ashrafxbilal/terraform-provider-spotify/spotify/errors" // Uncomment when needed
 	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/logging"
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/spotifyuri"
 	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/utils"
 )
 
+// Values of the on_destroy argument of spotify_playlist
+const (
+	playlistOnDestroyUnfollow         = "unfollow"
+	playlistOnDestroyClearAndUnfollow = "clear_and_unfollow"
+	playlistOnDestroyRetain           = "retain"
+)
+
 func resourceSpotifyPlaylist() *schema.Resource {
 	return &schema.Resource{
 		CreateContext: resourceSpotifyPlaylistCreate,
 		ReadContext:   resourceSpotifyPlaylistRead,
 		UpdateContext: resourceSpotifyPlaylistUpdate,
 		DeleteContext: resourceSpotifyPlaylistDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceSpotifyPlaylistImport,
+		},
+		CustomizeDiff: customdiff.All(
+			customizeDiffRequireScopes("spotify_playlist"),
+			customizeDiffPlaylistVisibility,
+		),
 		Schema: map[string]*schema.Schema{
 			"name": {
 				Type:        schema.TypeString,
@@ -41,16 +63,30 @@ func resourceSpotifyPlaylist() *schema.Resource {
 				Type:        schema.TypeBool,
 				Optional:    true,
 				Default:     false,
-				Description: "Whether the playlist is collaborative",
+				Description: "Whether the playlist is collaborative. Collaborative playlists must not be public.",
 			},
 			"tracks": {
 				Type:        schema.TypeList,
 				Optional:    true,
-				Description: "The tracks in the playlist",
+				Computed:    true,
+				Description: "The items in the playlist: track IDs, spotify:track:, spotify:episode: or spotify:local: URIs, or open.spotify.com links. Stored as URIs. Leave unset to manage the items elsewhere, e.g. with spotify_playlist_tracks.",
 				Elem: &schema.Schema{
-					Type: schema.TypeString,
+					Type:             schema.TypeString,
+					ValidateFunc:     validatePlaylistItem,
+					DiffSuppressFunc: suppressEquivalentPlaylistItem,
 				},
 			},
+			"on_destroy": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  playlistOnDestroyUnfollow,
+				ValidateFunc: validation.StringInSlice([]string{
+					playlistOnDestroyUnfollow,
+					playlistOnDestroyClearAndUnfollow,
+					playlistOnDestroyRetain,
+				}, false),
+				Description: "What to do with the playlist when the resource is destroyed: unfollow it (default), remove its tracks and make it private before unfollowing it (clear_and_unfollow), or only remove it from state (retain)",
+			},
 			"snapshot_id": {
 				Type:        schema.TypeString,
 				Computed:    true,
@@ -76,6 +112,10 @@ func resourceSpotifyPlaylist() *schema.Resource {
 }
 
 func resourceSpotifyPlaylistCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := requireUserAuth(m, "spotify_playlist"); diags != nil {
+		return diags
+	}
+
 	client := m.(*ProviderClient).SpotifyClient
 	logger := logging.DefaultLogger.WithContext(ctx)
 
@@ -101,30 +141,45 @@ func resourceSpotifyPlaylistCreate(ctx context.Context, d *schema.ResourceData,
 		})
 	}
 
-	playlist, err := client.CreatePlaylistForUser(ctx, user.ID, name, description, public, collaborative)
+	tracks, err := expandPlaylistItems(d.Get("tracks").([]interface{}))
 	if err != nil {
-		return utils.HandleAPIError(ctx, err, "create", "playlist", name)
+		return diag.FromErr(err)
+	}
+	if err := checkAddablePlaylistItems(tracks); err != nil {
+		return diag.FromErr(err)
 	}
 
-	// Add tracks if specified
-	if v, ok := d.GetOk("tracks"); ok {
-		tracks := expandTracks(v.([]interface{}))
-		if len(tracks) > 0 {
-			logger.Info("Adding tracks to playlist", "playlist_id", playlist.ID, "track_count", len(tracks))
-			_, err := client.AddTracksToPlaylist(ctx, playlist.ID, tracks...)
-			if err != nil {
-				return utils.HandleAPIError(ctx, err, "add tracks to", "playlist", string(playlist.ID))
-			}
-		}
+	playlist, err := client.CreatePlaylistForUser(ctx, user.ID, name, description, public, collaborative)
+	if err != nil {
+		return utils.HandleAPIError(ctx, err, "create", "playlist", name)
 	}
 
-	// Set the ID and other computed values
+	// Set the ID and other computed values before adding tracks, so the
+	// playlist is tainted rather than orphaned if adding them fails
 	playlistID := string(playlist.ID)
 	d.SetId(playlistID)
 	d.Set("snapshot_id", playlist.SnapshotID)
 	d.Set("spotify_url", playlist.ExternalURLs["spotify"])
 	d.Set("last_updated", time.Now().Format(time.RFC3339))
 
+	// Add tracks if specified
+	if len(tracks) > 0 {
+		logger.Info("Adding tracks to playlist", "playlist_id", playlistID, "track_count", len(tracks))
+		added, snapshotID, err := appendPlaylistItems(ctx, m.(*ProviderClient), playlist.ID, tracks)
+		if snapshotID != "" {
+			d.Set("snapshot_id", snapshotID)
+		}
+		if err != nil {
+			logger.Error("Failed to add tracks to playlist", "playlist_id", playlistID, "added", added, "track_count", len(tracks))
+			return diag.Diagnostics{{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Error adding tracks to playlist %s: added %d of %d tracks", playlistID, added, len(tracks)),
+				Detail: fmt.Sprintf("%s\n\nThe playlist was created and is marked as tainted, so it will be replaced on the next apply. "+
+					"The first %d tracks were added in order.", err, added),
+			}}
+		}
+	}
+
 	// Log successful operation
 	logger.Info("Successfully created playlist", "playlist_id", playlistID, "name", name)
 
@@ -132,6 +187,10 @@ func resourceSpotifyPlaylistCreate(ctx context.Context, d *schema.ResourceData,
 }
 
 func resourceSpotifyPlaylistRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := requireUserAuth(m, "spotify_playlist"); diags != nil {
+		return diags
+	}
+
 	var diags diag.Diagnostics
 	client := m.(*ProviderClient).SpotifyClient
 	logger := logging.DefaultLogger.WithContext(ctx)
@@ -163,12 +222,35 @@ func resourceSpotifyPlaylistRead(ctx context.Context, d *schema.ResourceData, m
 	d.Set("snapshot_id", playlist.SnapshotID)
 	d.Set("spotify_url", playlist.ExternalURLs["spotify"])
 
-	// Get the tracks
-	tracks, err := getPlaylistTracks(ctx, client, playlistID)
+	// Playlists imported, or created before on_destroy existed, have no value
+	// in state. Set the default so the next plan is clean.
+	if d.Get("on_destroy").(string) == "" {
+		d.Set("on_destroy", playlistOnDestroyUnfollow)
+	}
+
+	// Get the tracks, episodes and local files, scanning the playlist only if
+	// no other resource has at this snapshot
+	items, err := m.(*ProviderClient).playlistItems.get(ctx, client, playlistID, playlist.SnapshotID)
+	if errors.Is(err, errPlaylistChanged) {
+		items, _, err = getPlaylistItems(ctx, m.(*ProviderClient), playlistID)
+	}
 	if err != nil {
 		return utils.HandleAPIError(ctx, err, "read tracks for", "playlist", resourceID)
 	}
 
+	tracks := make([]string, 0, len(items))
+	for position, uri := range playlistItemURIs(items) {
+		if uri == "" {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Unavailable playlist item",
+				Detail:   fmt.Sprintf("The item at position %d of playlist %s is unavailable and is left out of tracks.", position, resourceID),
+			})
+			continue
+		}
+		tracks = append(tracks, uri)
+	}
+
 	d.Set("tracks", tracks)
 
 	// Log successful operation
@@ -178,62 +260,39 @@ func resourceSpotifyPlaylistRead(ctx context.Context, d *schema.ResourceData, m
 }
 
 func resourceSpotifyPlaylistUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	client := m.(*ProviderClient).SpotifyClient
-	playlistID := spotify.ID(d.Id())
-
-	// Update basic details if changed
-	if d.HasChanges("name", "description", "public", "collaborative") {
-		name := d.Get("name").(string)
-		description := d.Get("description").(string)
-		public := d.Get("public").(bool)
-
-		// Update playlist details
-		err := client.ChangePlaylistName(ctx, playlistID, name)
-		if err != nil {
-			return diag.FromErr(fmt.Errorf("error updating playlist name: %s", err))
-		}
+	if diags := requireUserAuth(m, "spotify_playlist"); diags != nil {
+		return diags
+	}
 
-		err = client.ChangePlaylistDescription(ctx, playlistID, description)
-		if err != nil {
-			return diag.FromErr(fmt.Errorf("error updating playlist description: %s", err))
-		}
+	client := m.(*ProviderClient)
+	playlistID := spotify.ID(d.Id())
 
-		err = client.ChangePlaylistAccess(ctx, playlistID, public)
-		if err != nil {
-			return diag.FromErr(fmt.Errorf("error updating playlist access: %s", err))
+	// Update the changed details with a single request
+	details := map[string]interface{}{}
+	for _, key := range []string{"name", "description", "public", "collaborative"} {
+		if d.HasChange(key) {
+			details[key] = d.Get(key)
 		}
-
-		// Collaborative status needs to be updated separately
-		if d.HasChange("collaborative") {
-			// Note: v2 API doesn't have SetPlaylistCollaborative, this would need to be handled differently
-			// For now, we'll log a warning
-			fmt.Printf("Warning: Setting collaborative status is not supported in the current Spotify API version")
+	}
+	if len(details) > 0 {
+		if err := changePlaylistDetails(ctx, client, playlistID, details); err != nil {
+			return diag.FromErr(fmt.Errorf("error updating playlist details: %s", err))
 		}
 	}
 
-	// Update tracks if changed
+	// Update tracks if changed, editing the playlist in place so unchanged
+	// tracks keep their added_at history
 	if d.HasChange("tracks") {
-		// Get current tracks
-		currentTracks, err := getPlaylistTracks(ctx, client, playlistID)
+		desiredTracks, err := expandPlaylistItems(d.Get("tracks").([]interface{}))
 		if err != nil {
-			return diag.FromErr(fmt.Errorf("error getting current playlist tracks: %s", err))
+			return diag.FromErr(err)
 		}
 
-		// Get desired tracks
-		desiredTracksRaw := d.Get("tracks").([]interface{})
-		desiredTracks := expandTracks(desiredTracksRaw)
-
-		// Convert current tracks to Spotify IDs
-		currentTrackIDs := make([]spotify.ID, len(currentTracks))
-		for i, track := range currentTracks {
-			currentTrackIDs[i] = spotify.ID(track)
-		}
-
-		// Replace all tracks
-		err = client.ReplacePlaylistTracks(ctx, playlistID, desiredTracks...)
+		snapshotID, err := reconcilePlaylistItems(ctx, client, playlistID, desiredTracks)
 		if err != nil {
-			return diag.FromErr(fmt.Errorf("error replacing playlist tracks: %s", err))
+			return diag.FromErr(fmt.Errorf("error updating playlist tracks: %s", err))
 		}
+		d.Set("snapshot_id", snapshotID)
 	}
 
 	d.Set("last_updated", time.Now().Format(time.RFC3339))
@@ -242,13 +301,39 @@ func resourceSpotifyPlaylistUpdate(ctx context.Context, d *schema.ResourceData,
 }
 
 func resourceSpotifyPlaylistDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := requireUserAuth(m, "spotify_playlist"); diags != nil {
+		return diags
+	}
+
 	var diags diag.Diagnostics
-	client := m.(*ProviderClient).SpotifyClient
+	client := m.(*ProviderClient)
+	logger := logging.DefaultLogger.WithContext(ctx)
+	playlistID := spotify.ID(d.Id())
+	onDestroy := d.Get("on_destroy").(string)
+
+	if onDestroy == playlistOnDestroyRetain {
+		logger.Info("Retaining playlist, removing it from state only", "playlist_id", d.Id())
+		d.SetId("")
+		return diags
+	}
+
+	// Empty the playlist and make it private first, so followers are not left
+	// with a public copy of it
+	if onDestroy == playlistOnDestroyClearAndUnfollow {
+		logger.Info("Clearing playlist before unfollowing it", "playlist_id", d.Id())
+
+		if _, err := reconcilePlaylistItems(ctx, client, playlistID, nil); err != nil {
+			return diag.FromErr(fmt.Errorf("error removing playlist tracks: %s", err))
+		}
+
+		if err := changePlaylistDetails(ctx, client, playlistID, map[string]interface{}{"public": false}); err != nil {
+			return diag.FromErr(fmt.Errorf("error making playlist private: %s", err))
+		}
+	}
 
 	// Spotify doesn't have a true delete operation for playlists
 	// Instead, we need to unfollow the playlist
-	playlistID := spotify.ID(d.Id())
-	err := client.UnfollowPlaylist(ctx, playlistID)
+	err := client.SpotifyClient.UnfollowPlaylist(ctx, playlistID)
 	if err != nil {
 		return diag.FromErr(fmt.Errorf("error unfollowing playlist: %s", err))
 	}
@@ -256,39 +341,76 @@ func resourceSpotifyPlaylistDelete(ctx context.Context, d *schema.ResourceData,
 	return diags
 }
 
+// resourceSpotifyPlaylistImport accepts a playlist ID, spotify: URI or open.spotify.com URL
+func resourceSpotifyPlaylistImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	playlistID, err := spotifyuri.ParseID(d.Id(), spotifyuri.Playlist)
+	if err != nil {
+		return nil, fmt.Errorf("error importing playlist: %s", err)
+	}
+
+	d.SetId(playlistID)
+
+	return []*schema.ResourceData{d}, nil
+}
+
 // Helper functions
 
-func expandTracks(tracks []interface{}) []spotify.ID {
-	result := make([]spotify.ID, len(tracks))
-	for i, v := range tracks {
-		result[i] = spotify.ID(v.(string))
+// customizeDiffPlaylistVisibility enforces Spotify's rule that collaborative
+// playlists must be private
+func customizeDiffPlaylistVisibility(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if d.Get("collaborative").(bool) && d.Get("public").(bool) {
+		return fmt.Errorf("a collaborative playlist must be private, set public = false or collaborative = false")
 	}
-	return result
+	return nil
 }
 
-func getPlaylistTracks(ctx context.Context, client *spotify.Client, playlistID spotify.ID) ([]string, error) {
-	var allTracks []string
-	limit := 100
-	offset := 0
+// changePlaylistDetails sends the given name, description, public and
+// collaborative values in a single request. The zmb3/spotify client has one
+// method per field and none for collaborative, so the request is made directly.
+func changePlaylistDetails(ctx context.Context, client *ProviderClient, playlistID spotify.ID, details map[string]interface{}) error {
+	body, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
 
-	for {
-		tracksPage, err := client.GetPlaylistItems(ctx, playlistID, spotify.Limit(limit), spotify.Offset(offset))
-		if err != nil {
-			return nil, err
-		}
+	req, err := http.NewRequestWithContext(ctx, "PUT", client.apiURL(fmt.Sprintf("playlists/%s", playlistID)), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
 
-		for _, item := range tracksPage.Items {
-			if item.Track.Track != nil {
-				allTracks = append(allTracks, string(item.Track.Track.ID))
-			}
-		}
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer utils.HandleResponseBodyClose(ctx, resp)
 
-		if len(tracksPage.Items) < limit {
-			break
-		}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return decodeAPIError(resp.StatusCode, data)
+	}
 
-		offset += limit
+	return nil
+}
+
+// validatePlaylistItem checks that a tracks entry is a track, episode or local file
+func validatePlaylistItem(v interface{}, k string) ([]string, []error) {
+	if _, err := spotifyuri.NormalizePlaylistItem(v.(string)); err != nil {
+		return nil, []error{fmt.Errorf("%s: %s", k, err)}
 	}
+	return nil, nil
+}
 
-	return allTracks, nil
-}
\ No newline at end of file
+// suppressEquivalentPlaylistItem hides differences between forms of the same
+// item, such as a track ID in the configuration and its URI in state
+func suppressEquivalentPlaylistItem(k, old, new string, d *schema.ResourceData) bool {
+	oldURI, err := spotifyuri.NormalizePlaylistItem(old)
+	if err != nil {
+		return false
+	}
+	newURI, err := spotifyuri.NormalizePlaylistItem(new)
+	if err != nil {
+		return false
+	}
+	return oldURI == newURI
+}
@@ -3,13 +3,14 @@ package spotify
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
-	"image/jpeg"
 	"io"
 	"math"
 	"math/rand"
@@ -19,11 +20,15 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/coverimage"
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/logging"
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/spotifyuri"
 	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/utils"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"github.com/zmb3/spotify/v2"
-	"golang.org/x/oauth2"
 )
 
 func resourceSpotifyPlaylistCover() *schema.Resource {
@@ -32,6 +37,14 @@ func resourceSpotifyPlaylistCover() *schema.Resource {
 		ReadContext:   resourceSpotifyPlaylistCoverRead,
 		UpdateContext: resourceSpotifyPlaylistCoverUpdate,
 		DeleteContext: resourceSpotifyPlaylistCoverDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceSpotifyPlaylistCoverImport,
+		},
+		CustomizeDiff: customdiff.All(
+			customizeDiffRequireScopes("spotify_playlist_cover"),
+			customizeDiffPlaylistCoverDrift,
+			customizeDiffPlaylistCoverChecksum,
+		),
 		Schema: map[string]*schema.Schema{
 			"playlist_id": {
 				Type:        schema.TypeString,
@@ -40,9 +53,24 @@ func resourceSpotifyPlaylistCover() *schema.Resource {
 				Description: "The ID of the playlist",
 			},
 			"image_url": {
-				Type:        schema.TypeString,
-				Optional:    true,
-				Description: "URL of the image to use as playlist cover",
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"image_file", "image_base64"},
+				Description:   "URL of the image to use as playlist cover",
+			},
+			"image_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"image_url", "image_base64"},
+				Description:   "Path of a local image file to use as playlist cover, e.g. \"${path.module}/cover.jpg\"",
+			},
+			"image_base64": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ConflictsWith:    []string{"image_url", "image_file"},
+				ValidateFunc:     validation.StringIsBase64,
+				DiffSuppressFunc: suppressEquivalentBase64,
+				Description:      "Base64 encoded image to use as playlist cover",
 			},
 			"emoji": {
 				Type:        schema.TypeString,
@@ -65,12 +93,122 @@ func resourceSpotifyPlaylistCover() *schema.Resource {
 				Default:     "#1DB954", // Spotify green
 				Description: "Background color for the generated cover image (hex code)",
 			},
+			"emoji_layout": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      coverimage.EmojiCenter,
+				ValidateFunc: validation.StringInSlice([]string{coverimage.EmojiCenter, coverimage.EmojiTiled, coverimage.EmojiNone}, false),
+				Description:  "How the emoji is drawn onto the generated cover: center, tiled or none",
+			},
+			"emoji_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      coverimage.DefaultEmojiSize,
+				ValidateFunc: validation.IntBetween(16, 300),
+				Description:  "Width and height in pixels of the emoji, on the 300x300 generated cover",
+			},
 			"force_update": {
 				Type:        schema.TypeBool,
 				Optional:    true,
 				Default:     false,
+				Deprecated:  "Covers changed outside Terraform are now detected and replaced, force_update is no longer needed",
 				Description: "Force update the playlist cover image even if no changes are detected",
 			},
+			"image_sha256": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "SHA-256 checksum of the image_file or image_base64 content. A new checksum uploads the cover again.",
+			},
+			"title": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"image_url", "image_file", "image_base64"},
+				Description:   "Title drawn in bold onto the generated cover, e.g. the playlist name",
+			},
+			"subtitle": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"image_url", "image_file", "image_base64"},
+				Description:   "Subtitle drawn below the title onto the generated cover",
+			},
+			"title_font_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      coverimage.DefaultTitleSize,
+				ValidateFunc: validation.IntBetween(8, 120),
+				Description:  "Font size of the title in pixels, on the 300x300 generated cover. Long titles are shrunk to fit.",
+			},
+			"subtitle_font_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      coverimage.DefaultSubtitleSize,
+				ValidateFunc: validation.IntBetween(8, 120),
+				Description:  "Font size of the subtitle in pixels, on the 300x300 generated cover",
+			},
+			"text_align": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      coverimage.AlignLeft,
+				ValidateFunc: validation.StringInSlice([]string{coverimage.AlignLeft, coverimage.AlignCenter, coverimage.AlignRight}, false),
+				Description:  "Horizontal alignment of the title and subtitle: left, center or right",
+			},
+			"text_position": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      coverimage.PositionBottom,
+				ValidateFunc: validation.StringInSlice([]string{coverimage.PositionTop, coverimage.PositionCenter, coverimage.PositionBottom}, false),
+				Description:  "Vertical position of the title and subtitle: top, center or bottom",
+			},
+			"text_color": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateHexColor,
+				Description:  "Color of the title and subtitle (hex code). Defaults to black or white, whichever contrasts more with the cover behind the text.",
+			},
+			"text_padding": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      coverimage.DefaultPadding,
+				ValidateFunc: validation.IntBetween(0, 100),
+				Description:  "Margin in pixels kept free of text on every side of the 300x300 generated cover",
+			},
+			"image_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      coverimage.DefaultSize,
+				ValidateFunc: validation.IntBetween(coverimage.MinSize, coverimage.MaxSize),
+				Description:  "Width and height in pixels the cover is cropped and scaled to before it is uploaded",
+			},
+			"image_width": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Width in pixels of the uploaded cover",
+			},
+			"image_height": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Height in pixels of the uploaded cover",
+			},
+			"image_quality": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "JPEG quality of the uploaded cover, lowered until the cover fits Spotify's 256 KB limit",
+			},
+			"image_bytes": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Size in bytes of the uploaded JPEG, before base64 encoding",
+			},
+			"image_hash": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Perceptual hash of the uploaded cover image",
+			},
+			"live_image_hash": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Perceptual hash of the cover image Spotify currently shows for the playlist",
+			},
 			"last_updated": {
 				Type:        schema.TypeString,
 				Computed:    true,
@@ -86,7 +224,11 @@ func resourceSpotifyPlaylistCover() *schema.Resource {
 }
 
 func resourceSpotifyPlaylistCoverCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	client := m.(*ProviderClient).SpotifyClient
+	if diags := requireUserAuth(m, "spotify_playlist_cover"); diags != nil {
+		return diags
+	}
+
+	client := m.(*ProviderClient)
 	playlistID := spotify.ID(d.Get("playlist_id").(string))
 
 	// Generate a unique ID for this resource
@@ -107,23 +249,96 @@ func resourceSpotifyPlaylistCoverCreate(ctx context.Context, d *schema.ResourceD
 		}}
 	}
 
-	return resourceSpotifyPlaylistCoverRead(ctx, d, m)
+	// Spotify processes uploads asynchronously, the live image is compared on the next refresh
+	return readPlaylistCover(ctx, d, m, false)
 }
 
 func resourceSpotifyPlaylistCoverRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := requireUserAuth(m, "spotify_playlist_cover"); diags != nil {
+		return diags
+	}
+
+	return readPlaylistCover(ctx, d, m, true)
+}
+
+// readPlaylistCover checks that the playlist still exists and, when
+// compareLive is set, hashes the cover Spotify shows for it into
+// live_image_hash, so customizeDiffPlaylistCoverDrift can detect covers
+// changed outside Terraform
+func readPlaylistCover(ctx context.Context, d *schema.ResourceData, m interface{}, compareLive bool) diag.Diagnostics {
 	var diags diag.Diagnostics
-	// Nothing to read as Spotify doesn't provide an API to get the current cover image data
-	// We can only see the image URL in the playlist object, but not compare with our source
+	client := m.(*ProviderClient).SpotifyClient
+	playlistID := d.Get("playlist_id").(string)
+
+	playlist, err := client.GetPlaylist(ctx, spotify.ID(playlistID), spotify.Fields("id,images"))
+	if err != nil {
+		if utils.IsSpotifyNotFoundError(err) {
+			d.SetId("")
+			return diags
+		}
+		return utils.HandleAPIError(ctx, err, "read", "playlist cover", playlistID)
+	}
+
+	if !compareLive || len(playlist.Images) == 0 {
+		return diags
+	}
+
+	// Images are listed largest first
+	liveHash, err := hashImageURL(ctx, playlist.Images[0].URL)
+	if err != nil {
+		return append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Could not check the playlist cover for changes",
+			Detail:   fmt.Sprintf("Error reading the cover image of playlist %s: %s", playlistID, err),
+		})
+	}
+
+	d.Set("live_image_hash", liveHash)
+
+	// Covers uploaded before image_hash existed, or imported ones, are
+	// taken as they are
+	if d.Get("image_hash").(string) == "" {
+		d.Set("image_hash", liveHash)
+	}
+
 	return diags
 }
 
 func resourceSpotifyPlaylistCoverUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	client := m.(*ProviderClient).SpotifyClient
+	if diags := requireUserAuth(m, "spotify_playlist_cover"); diags != nil {
+		return diags
+	}
+
+	client := m.(*ProviderClient)
 
-	// Check if any of the fields that affect the image have changed or if force_update is true
+	// Check if any of the fields that affect the image have changed or if force_update is true.
+	// image_file and image_base64 are compared by content, through image_sha256,
+	// so moving the same image from one to the other does not upload it again.
 	forceUpdate := d.Get("force_update").(bool)
-	imageChanged := d.HasChange("image_url") || d.HasChange("emoji") || d.HasChange("mood") ||
-		d.HasChange("weather") || d.HasChange("background_color")
+	imageChanged := d.HasChanges("image_url", "image_sha256", "emoji", "mood", "weather", "background_color")
+
+	// Text settings only matter when there is text. This also keeps covers
+	// uploaded before they existed from being uploaded again.
+	if d.HasChanges("title", "subtitle") || (hasCoverText(d) && d.HasChanges("title_font_size", "subtitle_font_size",
+		"text_align", "text_position", "text_color", "text_padding")) {
+		imageChanged = true
+	}
+
+	// Covers uploaded before image_size existed have no size in state, and
+	// are not uploaded again only to record the default
+	if oldSize, _ := d.GetChange("image_size"); d.HasChange("image_size") && oldSize.(int) != 0 {
+		imageChanged = true
+	}
+
+	// Likewise for the emoji settings, which only matter for generated covers
+	if !hasImageSource(d) {
+		if oldLayout, _ := d.GetChange("emoji_layout"); d.HasChange("emoji_layout") && oldLayout.(string) != "" {
+			imageChanged = true
+		}
+		if oldEmojiSize, _ := d.GetChange("emoji_size"); d.HasChange("emoji_size") && oldEmojiSize.(int) != 0 {
+			imageChanged = true
+		}
+	}
 
 	// Only update if there are changes or force_update is true
 	if imageChanged || forceUpdate {
@@ -141,12 +356,18 @@ func resourceSpotifyPlaylistCoverUpdate(ctx context.Context, d *schema.ResourceD
 				Detail:   "Could not set last_updated timestamp",
 			}}
 		}
+
+		return readPlaylistCover(ctx, d, m, false)
 	}
 
 	return resourceSpotifyPlaylistCoverRead(ctx, d, m)
 }
 
 func resourceSpotifyPlaylistCoverDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := requireUserAuth(m, "spotify_playlist_cover"); diags != nil {
+		return diags
+	}
+
 	var diags diag.Diagnostics
 	// Spotify doesn't provide an API to delete a playlist cover image
 	// The cover will remain until replaced
@@ -154,26 +375,50 @@ func resourceSpotifyPlaylistCoverDelete(ctx context.Context, d *schema.ResourceD
 	return diags
 }
 
-func setPlaylistCoverImage(ctx context.Context, d *schema.ResourceData, client *spotify.Client) diag.Diagnostics {
+// resourceSpotifyPlaylistCoverImport accepts a playlist ID, spotify: URI or open.spotify.com URL
+func resourceSpotifyPlaylistCoverImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	playlistID, err := spotifyuri.ParseID(d.Id(), spotifyuri.Playlist)
+	if err != nil {
+		return nil, fmt.Errorf("error importing playlist cover: %s", err)
+	}
+
+	// Defaults are not applied on import, set them so the next plan is clean
+	for key, s := range resourceSpotifyPlaylistCover().Schema {
+		if s.Default != nil {
+			d.Set(key, s.Default)
+		}
+	}
+	d.Set("playlist_id", playlistID)
+	d.SetId(fmt.Sprintf("%s-cover-%d", playlistID, time.Now().Unix()))
+
+	return []*schema.ResourceData{d}, nil
+}
+
+func setPlaylistCoverImage(ctx context.Context, d *schema.ResourceData, client *ProviderClient) diag.Diagnostics {
 	var diags diag.Diagnostics
 	playlistID := spotify.ID(d.Get("playlist_id").(string))
 
-	// Get image data as base64
-	imageData, err := getImageData(d)
+	// Get the image as a JPEG that Spotify accepts
+	cover, err := getImageData(ctx, d)
 	if err != nil {
 		return diag.FromErr(fmt.Errorf("error preparing image data: %s", err))
 	}
 
 	// The Spotify API endpoint for setting a playlist cover image
 	// is not directly exposed in the zmb3/spotify library, so we need to make a direct API call
-	// URL: https://api.spotify.com/v1/playlists/{playlist_id}/images
+	// URL: {api_base_url}/v1/playlists/{playlist_id}/images
 
 	// Create the request URL
-	url := fmt.Sprintf("https://api.spotify.com/v1/playlists/%s/images", playlistID)
+	url := client.apiURL(fmt.Sprintf("playlists/%s/images", playlistID))
+
+	// The provider's client has no timeout of its own, so a stalled upload
+	// would otherwise hold on until the resource timeout
+	uploadCtx, cancel := context.WithTimeout(ctx, coverUploadTimeout)
+	defer cancel()
 
 	// Create a new HTTP request
 	// Important: Spotify expects the raw base64 string without any prefixes
-	req, err := http.NewRequestWithContext(ctx, "PUT", url, strings.NewReader(imageData))
+	req, err := http.NewRequestWithContext(uploadCtx, "PUT", url, strings.NewReader(cover.Base64()))
 	if err != nil {
 		return diag.FromErr(fmt.Errorf("error creating request: %s", err))
 	}
@@ -181,22 +426,9 @@ func setPlaylistCoverImage(ctx context.Context, d *schema.ResourceData, client *
 	// Set the content type header - this is important for Spotify's API
 	req.Header.Set("Content-Type", "image/jpeg")
 
-	// Get the access token
-	token := getAccessToken(ctx)
-	if token == "" {
-		return diag.FromErr(fmt.Errorf("failed to get access token"))
-	}
-
-	// Add the Authorization header to the request
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	// Create a new HTTP client with reasonable timeouts
-	httpClient := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	// Make the request
-	resp, err := httpClient.Do(req)
+	// Make the request through the provider's authenticated, rate-limit-aware client.
+	// It adds the Authorization header from the shared token source.
+	resp, err := client.HTTPClient.Do(req)
 	if err != nil {
 		return diag.FromErr(fmt.Errorf("error uploading image: %s", err))
 	}
@@ -209,59 +441,326 @@ func setPlaylistCoverImage(ctx context.Context, d *schema.ResourceData, client *
 		return diag.FromErr(fmt.Errorf("error from Spotify API: %s (status code: %d)", string(body), resp.StatusCode))
 	}
 
+	// Remember what the uploaded image looks like, to detect later changes
+	imageHash, err := hashImageData(cover.Data)
+	if err != nil {
+		logging.DefaultLogger.WithContext(ctx).Warn("Could not hash the uploaded cover image, changes made outside Terraform will not be detected",
+			"playlist_id", string(playlistID),
+			"error", err.Error(),
+		)
+	}
+	d.Set("image_hash", imageHash)
+	d.Set("live_image_hash", imageHash)
+
+	d.Set("image_width", cover.Width)
+	d.Set("image_height", cover.Height)
+	d.Set("image_quality", cover.Quality)
+	d.Set("image_bytes", len(cover.Data))
+
+	checksum, err := imageSourceSHA256(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	d.Set("image_sha256", checksum)
+
 	return diags
 }
 
-func getImageData(d *schema.ResourceData) (string, error) {
+// maxCoverDownloadSize bounds the size of a cover image read from Spotify
+const maxCoverDownloadSize = 10 << 20
+
+// hashImageData returns the perceptual hash of an encoded image
+func hashImageData(data []byte) (string, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	return coverimage.FormatHash(coverimage.Hash(img)), nil
+}
+
+// hashImageURL downloads an image and returns its perceptual hash
+func hashImageURL(ctx context.Context, url string) (string, error) {
+	data, err := downloadImage(ctx, url)
+	if err != nil {
+		return "", err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	return coverimage.FormatHash(coverimage.Hash(img)), nil
+}
+
+// coverUploadTimeout bounds a single cover upload to Spotify
+const coverUploadTimeout = 30 * time.Second
+
+// imageDownloadClient downloads cover images. Images are public, so the
+// provider's authenticated client is not used and no token leaves Spotify.
+var imageDownloadClient = &http.Client{Timeout: 30 * time.Second}
+
+// downloadImage returns the content of an image URL, up to maxCoverDownloadSize bytes
+func downloadImage(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := imageDownloadClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer utils.HandleResponseBodyClose(ctx, resp)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCoverDownloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxCoverDownloadSize {
+		return nil, fmt.Errorf("image is larger than %d bytes", maxCoverDownloadSize)
+	}
+
+	return data, nil
+}
+
+// resourceGetter reads attributes from a ResourceData or a ResourceDiff
+type resourceGetter interface {
+	Get(key string) interface{}
+	GetOk(key string) (interface{}, bool)
+}
+
+// hasImageSource reports whether the cover comes from an image rather than
+// being generated
+func hasImageSource(d resourceGetter) bool {
+	for _, key := range []string{"image_url", "image_file", "image_base64"} {
+		if _, ok := d.GetOk(key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// readImageSource returns the content of image_file or image_base64, or nil
+// when the cover comes from another source
+func readImageSource(d resourceGetter) ([]byte, error) {
+	if path, ok := d.GetOk("image_file"); ok {
+		data, err := os.ReadFile(path.(string))
+		if err != nil {
+			return nil, fmt.Errorf("error reading image_file: %w", err)
+		}
+		return data, nil
+	}
+
+	if encoded, ok := d.GetOk("image_base64"); ok {
+		data, err := base64.StdEncoding.DecodeString(encoded.(string))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding image_base64: %w", err)
+		}
+		return data, nil
+	}
+
+	return nil, nil
+}
+
+// suppressEquivalentBase64 hides differences in the encoding of the same
+// image_base64 content, such as line breaks
+func suppressEquivalentBase64(k, old, new string, d *schema.ResourceData) bool {
+	oldData, err := base64.StdEncoding.DecodeString(old)
+	if err != nil {
+		return false
+	}
+	newData, err := base64.StdEncoding.DecodeString(new)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(oldData, newData)
+}
+
+// imageSourceSHA256 returns the hex SHA-256 checksum of image_file or
+// image_base64, or "" when the cover comes from another source
+func imageSourceSHA256(d resourceGetter) (string, error) {
+	data, err := readImageSource(d)
+	if err != nil || data == nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// customizeDiffPlaylistCoverChecksum plans image_sha256 from the current
+// content of image_file or image_base64, so editing the file uploads the
+// cover again even though its path is unchanged
+func customizeDiffPlaylistCoverChecksum(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if !d.NewValueKnown("image_file") || !d.NewValueKnown("image_base64") {
+		return d.SetNewComputed("image_sha256")
+	}
+
+	checksum, err := imageSourceSHA256(d)
+	if err != nil {
+		return err
+	}
+
+	if checksum != d.Get("image_sha256").(string) {
+		return d.SetNew("image_sha256", checksum)
+	}
+	return nil
+}
+
+// customizeDiffPlaylistCoverDrift replaces the cover when the image Spotify
+// shows no longer looks like the one that was uploaded, e.g. because it was
+// changed in the Spotify app. The plan shows live_image_hash changing back to
+// image_hash.
+func customizeDiffPlaylistCoverDrift(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if d.Id() == "" {
+		return nil
+	}
+
+	uploaded, err := coverimage.ParseHash(d.Get("image_hash").(string))
+	if err != nil {
+		return nil
+	}
+	live, err := coverimage.ParseHash(d.Get("live_image_hash").(string))
+	if err != nil {
+		return nil
+	}
+
+	if coverimage.Distance(uploaded, live) <= coverimage.DriftThreshold {
+		return nil
+	}
+
+	if err := d.SetNew("live_image_hash", coverimage.FormatHash(uploaded)); err != nil {
+		return err
+	}
+	return d.ForceNew("live_image_hash")
+}
+
+// getImageData returns the configured cover, cropped, scaled to image_size
+// and encoded as a JPEG that fits Spotify's size limit
+func getImageData(ctx context.Context, d *schema.ResourceData) (*coverimage.Result, error) {
+	size := d.Get("image_size").(int)
+
 	// Check if image_url is provided
 	if imageURL, ok := d.GetOk("image_url"); ok {
 		// Download the image from the URL
-		resp, err := http.Get(imageURL.(string))
+		imageBytes, err := downloadImage(ctx, imageURL.(string))
 		if err != nil {
-			return "", fmt.Errorf("error downloading image: %w", err)
+			return nil, fmt.Errorf("error downloading image: %w", err)
 		}
-		// Use a separate function to safely close the response body
-		ctx := context.Background() // Use background context since we don't have one passed in
-		defer utils.HandleResponseBodyClose(ctx, resp)
 
-		// Read the image data
-		imageBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "", fmt.Errorf("error reading image data: %w", err)
-		}
+		return coverimage.Prepare(imageBytes, size)
+	}
+
+	// Check if image_file or image_base64 is provided
+	imageBytes, err := readImageSource(d)
+	if err != nil {
+		return nil, err
+	}
+	if imageBytes != nil {
+		return coverimage.Prepare(imageBytes, size)
+	}
 
-		// Encode the image as base64
-		return base64.StdEncoding.EncodeToString(imageBytes), nil
+	// Otherwise generate a cover from an emoji
+	emoji := "🎵"
+	if v, ok := d.GetOk("emoji"); ok {
+		emoji = v.(string)
+	} else if mood, ok := d.GetOk("mood"); ok {
+		emoji = getMoodEmoji(mood.(string))
+	} else if weather, ok := d.GetOk("weather"); ok {
+		emoji = getWeatherEmoji(weather.(string))
 	}
 
-	// If emoji is provided, generate an image with the emoji
-	if emoji, ok := d.GetOk("emoji"); ok {
-		return generatePatternCoverImage(emoji.(string), d.Get("background_color").(string))
+	// Sizes are configured on the canvas, the cover is drawn at image_size
+	scale := float64(size) / coverimage.CanvasSize
+
+	img := generatePatternCoverImage(emoji, d.Get("background_color").(string), size)
+	drawCoverEmoji(ctx, img, emoji, d, scale)
+	if err := drawCoverText(img, d, scale); err != nil {
+		return nil, err
 	}
 
-	// If mood is provided, select an appropriate emoji
-	if mood, ok := d.GetOk("mood"); ok {
-		emoji := getMoodEmoji(mood.(string))
-		return generatePatternCoverImage(emoji, d.Get("background_color").(string))
+	return coverimage.Encode(img, size)
+}
+
+// drawCoverEmoji draws the emoji onto a generated cover as configured by
+// emoji_layout and emoji_size, scaled by scale. Emojis without an embedded
+// glyph leave only the pattern.
+func drawCoverEmoji(ctx context.Context, img *image.RGBA, emoji string, d *schema.ResourceData, scale float64) {
+	layout := d.Get("emoji_layout").(string)
+	if layout == coverimage.EmojiNone {
+		return
 	}
 
-	// If weather is provided, select an appropriate emoji
-	if weather, ok := d.GetOk("weather"); ok {
-		emoji := getWeatherEmoji(weather.(string))
-		return generatePatternCoverImage(emoji, d.Get("background_color").(string))
+	glyph, ok := coverimage.Emoji(emoji)
+	if !ok {
+		logging.DefaultLogger.WithContext(ctx).Warn("No glyph is embedded for the emoji, the cover shows only the pattern",
+			"emoji", emoji,
+		)
+		return
 	}
 
-	// Default emoji if nothing else is provided
-	return generatePatternCoverImage("🎵", d.Get("background_color").(string))
+	coverimage.DrawEmoji(img, glyph, layout, scaleCoverSize(d.Get("emoji_size").(int), scale))
+}
+
+// hasCoverText reports whether a title or subtitle is configured
+func hasCoverText(d *schema.ResourceData) bool {
+	return d.Get("title").(string) != "" || d.Get("subtitle").(string) != ""
 }
 
-func generatePatternCoverImage(emoji string, backgroundColor string) (string, error) {
+// drawCoverText draws the configured title and subtitle onto a generated
+// cover, with font sizes and padding scaled by scale
+func drawCoverText(img *image.RGBA, d *schema.ResourceData, scale float64) error {
+	text := coverimage.Text{
+		Title:        d.Get("title").(string),
+		Subtitle:     d.Get("subtitle").(string),
+		TitleSize:    float64(d.Get("title_font_size").(int)) * scale,
+		SubtitleSize: float64(d.Get("subtitle_font_size").(int)) * scale,
+		Align:        d.Get("text_align").(string),
+		Position:     d.Get("text_position").(string),
+		Padding:      scaleCoverSize(d.Get("text_padding").(int), scale),
+	}
+
+	if v, ok := d.GetOk("text_color"); ok {
+		textColor, err := parseHexColor(v.(string))
+		if err != nil {
+			return err
+		}
+		text.Color = textColor
+	}
+
+	if err := coverimage.DrawText(img, text); err != nil {
+		return fmt.Errorf("error drawing cover text: %w", err)
+	}
+	return nil
+}
+
+// scaleCoverSize scales a length configured on the canvas of a generated cover
+func scaleCoverSize(v int, scale float64) int {
+	return int(math.Round(float64(v) * scale))
+}
+
+// validateHexColor checks that a color is a hex code such as #1DB954
+func validateHexColor(v interface{}, k string) ([]string, []error) {
+	if _, err := parseHexColor(v.(string)); err != nil {
+		return nil, []error{fmt.Errorf("%s: %s", k, err)}
+	}
+	return nil, nil
+}
+
+// generatePatternCoverImage draws a size x size pattern themed after the emoji
+func generatePatternCoverImage(emoji string, backgroundColor string, size int) *image.RGBA {
 	// We don't need to seed crypto/rand as it's automatically seeded with secure entropy
 	// The hash is still useful for deterministic patterns if needed
 	//seedValue := hash(emoji + backgroundColor)
 
-	// Create a 300x300 RGBA image
-	img := image.NewRGBA(image.Rect(0, 0, 300, 300))
+	// Create a size x size RGBA image
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
 
 	// Get the primary color based on the emoji/mood/weather
 	primaryColor := getThemeColor(emoji)
@@ -303,17 +802,13 @@ func generatePatternCoverImage(emoji string, backgroundColor string) (string, er
 		drawGradient(img, primaryColor, secondaryColor)
 	}
 
-	// Encode the image as JPEG
-	var buf bytes.Buffer
-	opts := jpeg.Options{
-		Quality: 90,
-	}
-	if err := jpeg.Encode(&buf, img, &opts); err != nil {
-		return "", fmt.Errorf("error encoding JPEG: %w", err)
-	}
+	return img
+}
 
-	// Convert JPEG to base64
-	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+// patternScale returns how much larger img is than the canvas the pattern
+// dimensions are given for
+func patternScale(img *image.RGBA) float64 {
+	return float64(img.Bounds().Dx()) / coverimage.CanvasSize
 }
 
 // drawGradient creates a gradient from top-left to bottom-right
@@ -345,9 +840,10 @@ func drawWavePattern(img *image.RGBA, primaryColor, secondaryColor color.RGBA) {
 	draw.Draw(img, bounds, &image.Uniform{secondaryColor}, image.Point{}, draw.Src)
 
 	// Draw waves with primary color
+	scale := patternScale(img)
 	for y := 0; y < height; y++ {
 		// Calculate wave amplitude based on y position
-		amplitude := 30.0 * math.Sin(float64(y)/20.0)
+		amplitude := 30.0 * scale * math.Sin(float64(y)/(20.0*scale))
 
 		for x := 0; x < width; x++ {
 			// Create wave effect
@@ -367,8 +863,10 @@ func drawRayPattern(img *image.RGBA, primaryColor, secondaryColor color.RGBA) {
 	// Fill with secondary color first
 	draw.Draw(img, bounds, &image.Uniform{secondaryColor}, image.Point{}, draw.Src)
 
-	// Draw rays from center
+	// Draw rays from center, with as many offsets per ray as it is wide
+	scale := patternScale(img)
 	numRays := 12
+	numOffsets := int(20 * scale)
 	for i := 0; i < numRays; i++ {
 		angle := float64(i) * (2 * math.Pi / float64(numRays))
 
@@ -383,8 +881,8 @@ func drawRayPattern(img *image.RGBA, primaryColor, secondaryColor color.RGBA) {
 				img.Set(x, y, primaryColor)
 
 				// Make ray thicker
-				for w := 1; w < 20; w++ {
-					offsetAngle := angle + float64(w)*0.01
+				for w := 1; w < numOffsets; w++ {
+					offsetAngle := angle + float64(w)*0.2/float64(numOffsets)
 					offsetX := centerX + int(float64(r)*math.Cos(offsetAngle))
 					offsetY := centerY + int(float64(r)*math.Sin(offsetAngle))
 
@@ -407,11 +905,13 @@ func drawCirclePattern(img *image.RGBA, primaryColor, secondaryColor color.RGBA)
 	draw.Draw(img, bounds, &image.Uniform{secondaryColor}, image.Point{}, draw.Src)
 
 	// Draw concentric circles
+	spacing := scaleCoverSize(20, patternScale(img))
+	thickness := spacing / 2
 	maxRadius := int(math.Sqrt(float64(width*width+height*height)) / 2)
-	for r := maxRadius; r > 0; r -= 20 {
+	for r := maxRadius; r > 0; r -= spacing {
 		// Alternate colors
 		circleColor := primaryColor
-		if (r/20)%2 == 0 {
+		if (r/spacing)%2 == 0 {
 			circleColor = secondaryColor
 		}
 
@@ -423,7 +923,7 @@ func drawCirclePattern(img *image.RGBA, primaryColor, secondaryColor color.RGBA)
 				distance := int(math.Sqrt(float64(dx*dx + dy*dy)))
 
 				// Draw circle with some thickness
-				if distance <= r && distance > r-10 {
+				if distance <= r && distance > r-thickness {
 					img.Set(x, y, circleColor)
 				}
 			}
@@ -440,13 +940,15 @@ func drawDotPattern(img *image.RGBA, primaryColor, secondaryColor color.RGBA) {
 	draw.Draw(img, bounds, &image.Uniform{secondaryColor}, image.Point{}, draw.Src)
 
 	// Draw dots in a grid pattern
-	dotSpacing := 30
-	dotRadius := 10
+	scale := patternScale(img)
+	dotSpacing := scaleCoverSize(30, scale)
+	dotRadius := scaleCoverSize(10, scale)
+	jitter := scaleCoverSize(2, scale)
 
 	for y := dotSpacing / 2; y < height; y += dotSpacing {
 		for x := dotSpacing / 2; x < width; x += dotSpacing {
 			// Add some randomness to dot positions using crypto/rand
-			offsetX, offsetY := secureRandomOffset(2)
+			offsetX, offsetY := secureRandomOffset(jitter)
 
 			// Draw dot
 			for dy := -dotRadius; dy <= dotRadius; dy++ {
@@ -666,65 +1168,6 @@ func getWeatherEmoji(weather string) string {
 	return "🌤️"
 }
 
-// getAccessToken retrieves the access token from the provider client
-// This is a workaround since we can't access the token directly from the Spotify client
-func getAccessToken(ctx context.Context) string {
-	// First, try to get the access token from the environment variable
-	token := os.Getenv("SPOTIFY_ACCESS_TOKEN")
-	if token != "" {
-		return token
-	}
-
-	// If the access token is not available, try to refresh it using the refresh token
-	fmt.Println("Access token not found, attempting to refresh")
-	refreshToken := os.Getenv("SPOTIFY_REFRESH_TOKEN")
-	if refreshToken == "" {
-		fmt.Println("Error: SPOTIFY_REFRESH_TOKEN environment variable not set")
-		return ""
-	}
-
-	// Get client credentials from environment variables
-	clientID := os.Getenv("SPOTIFY_CLIENT_ID")
-	clientSecret := os.Getenv("SPOTIFY_CLIENT_SECRET")
-	redirectURI := os.Getenv("SPOTIFY_REDIRECT_URI")
-
-	if clientID == "" || clientSecret == "" || redirectURI == "" {
-		fmt.Println("Error: Missing Spotify client credentials in environment variables")
-		return ""
-	}
-
-	// Create OAuth2 config
-	oauthConfig := &oauth2.Config{
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		RedirectURL:  redirectURI,
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  "https://accounts.spotify.com/authorize",
-			TokenURL: "https://accounts.spotify.com/api/token",
-		},
-		Scopes: []string{
-			"playlist-modify-public",
-			"playlist-modify-private",
-			"ugc-image-upload",
-		},
-	}
-
-	// Use refresh token to obtain a new access token
-	tkn := &oauth2.Token{RefreshToken: refreshToken}
-	tokenSource := oauthConfig.TokenSource(ctx, tkn)
-	newToken, err := tokenSource.Token()
-	if err != nil {
-		fmt.Printf("Error refreshing access token: %s\n", err)
-		return ""
-	}
-
-	// Save the new access token to the environment variable for future use
-	os.Setenv("SPOTIFY_ACCESS_TOKEN", newToken.AccessToken)
-
-	// Return the new access token
-	return newToken.AccessToken
-}
-
 // Simple hash function for seeding the random number generator
 func hash(s string) int {
 	h := 0
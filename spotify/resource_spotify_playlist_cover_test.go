@@ -0,0 +1,146 @@
+package spotify
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestPlaylistCoverImportPlansNoChanges(t *testing.T) {
+	_, client := newFakePlaylist(t)
+	resource := resourceSpotifyPlaylistCover()
+
+	d := resource.TestResourceData()
+	d.SetId("spotify:playlist:" + testPlaylistID)
+	imported, err := resourceSpotifyPlaylistCoverImport(context.Background(), d, client)
+	if err != nil {
+		t.Fatalf("Expected the cover to be imported, got %v", err)
+	}
+
+	state, diags := resource.RefreshWithoutUpgrade(context.Background(), imported[0].State(), client)
+	if diags.HasError() {
+		t.Fatalf("Expected the imported cover to be read, got %v", diags)
+	}
+
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{"playlist_id": testPlaylistID})
+	diff, err := resource.SimpleDiff(context.Background(), state, config, client)
+	if err != nil {
+		t.Fatalf("Expected a plan, got %v", err)
+	}
+	if diff != nil && !diff.Empty() {
+		t.Errorf("Expected no changes after import, got %v", diff.Attributes)
+	}
+}
+
+// testCoverImage returns a PNG image filled with the given color
+func testCoverImage(t *testing.T, c color.RGBA) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: c}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Error encoding test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// createPlaylistCover plans and applies a new cover with the given configuration
+func createPlaylistCover(t *testing.T, raw map[string]interface{}, m interface{}) *terraform.InstanceState {
+	t.Helper()
+
+	resource := resourceSpotifyPlaylistCover()
+	state, diff := planResource(t, resource, nil, raw, m)
+	state, diags := resource.Apply(context.Background(), state, diff, m)
+	if diags.HasError() {
+		t.Fatalf("Expected the cover to be created, got %v", diags)
+	}
+	return state
+}
+
+func TestPlaylistCoverUploadsChangedFileContent(t *testing.T) {
+	playlist, client := newFakePlaylist(t)
+	resource := resourceSpotifyPlaylistCover()
+
+	path := filepath.Join(t.TempDir(), "cover.png")
+	if err := os.WriteFile(path, testCoverImage(t, color.RGBA{R: 255, A: 255}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := map[string]interface{}{"playlist_id": testPlaylistID, "image_file": path}
+	state := createPlaylistCover(t, config, client)
+
+	// The same content plans nothing
+	_, diff := planResource(t, resource, state, config, client)
+	if !diff.Empty() {
+		t.Errorf("Expected no changes for the same file content, got %v", diff.Attributes)
+	}
+
+	// New content at the same path plans a new checksum, which uploads the cover again
+	changed := testCoverImage(t, color.RGBA{B: 255, A: 255})
+	if err := os.WriteFile(path, changed, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	state, diff = planResource(t, resource, state, config, client)
+	sum := sha256.Sum256(changed)
+	if attr := diff.Attributes["image_sha256"]; attr == nil || attr.New != hex.EncodeToString(sum[:]) {
+		t.Fatalf("Expected the checksum of the new content to be planned, got %v", diff.Attributes)
+	}
+	if _, diags := resource.Apply(context.Background(), state, diff, client); diags.HasError() {
+		t.Fatalf("Expected the cover to be updated, got %v", diags)
+	}
+	if playlist.coverUploads != 2 {
+		t.Errorf("Expected the changed cover to be uploaded, got %d uploads", playlist.coverUploads)
+	}
+}
+
+func TestPlaylistCoverIgnoresEncodingOfSameContent(t *testing.T) {
+	playlist, client := newFakePlaylist(t)
+	resource := resourceSpotifyPlaylistCover()
+
+	data := testCoverImage(t, color.RGBA{G: 255, A: 255})
+	encoded := base64.StdEncoding.EncodeToString(data)
+	state := createPlaylistCover(t, map[string]interface{}{"playlist_id": testPlaylistID, "image_base64": encoded}, client)
+
+	// Line breaks in the base64 content, as added by base64 on the command line
+	var wrapped strings.Builder
+	for i := 0; i < len(encoded); i += 76 {
+		end := i + 76
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		wrapped.WriteString(encoded[i:end] + "\n")
+	}
+	_, diff := planResource(t, resource, state, map[string]interface{}{"playlist_id": testPlaylistID, "image_base64": wrapped.String()}, client)
+	if !diff.Empty() {
+		t.Errorf("Expected no changes for re-encoded content, got %v", diff.Attributes)
+	}
+
+	// Moving the same image to a file changes the arguments, not the cover
+	path := filepath.Join(t.TempDir(), "cover.png")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	state, diff = planResource(t, resource, state, map[string]interface{}{"playlist_id": testPlaylistID, "image_file": path}, client)
+	if _, ok := diff.Attributes["image_sha256"]; ok {
+		t.Errorf("Expected the checksum to stay the same, got %v", diff.Attributes["image_sha256"])
+	}
+	if _, diags := resource.Apply(context.Background(), state, diff, client); diags.HasError() {
+		t.Fatalf("Expected the arguments to be updated, got %v", diags)
+	}
+	if playlist.coverUploads != 1 {
+		t.Errorf("Expected the cover not to be uploaded again, got %d uploads", playlist.coverUploads)
+	}
+}
@@ -0,0 +1,185 @@
+package spotify
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// interfaces converts a list of strings to a list attribute value
+func interfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
+
+func TestPlaylistCreateReportsPartiallyAddedTracks(t *testing.T) {
+	playlist, client := newFakePlaylist(t)
+	playlist.failInsert = 2
+
+	tracks := testTrackURIs(0, 250)
+	d := schema.TestResourceDataRaw(t, resourceSpotifyPlaylist().Schema, map[string]interface{}{
+		"name":   "Test",
+		"tracks": interfaces(tracks),
+	})
+
+	diags := resourceSpotifyPlaylistCreate(context.Background(), d, client)
+	if !diags.HasError() {
+		t.Fatal("Expected the failed batch to be reported")
+	}
+	if !strings.Contains(diags[0].Summary, "added 100 of 250 tracks") {
+		t.Errorf("Expected the error to report the added tracks, got %q", diags[0].Summary)
+	}
+
+	// The ID is set, so Terraform keeps the playlist in state as tainted
+	if d.Id() != testPlaylistID {
+		t.Errorf("Expected the ID to be set, got %q", d.Id())
+	}
+	if got := playlist.items(); !reflect.DeepEqual(got, tracks[:100]) {
+		t.Errorf("Expected the first batch to be added, got %d items", len(got))
+	}
+	if got := d.Get("snapshot_id").(string); got != playlist.snapshotID() {
+		t.Errorf("Expected the snapshot ID of the first batch %q, got %q", playlist.snapshotID(), got)
+	}
+}
+
+func TestChangePlaylistDetailsSendsSingleRequest(t *testing.T) {
+	playlist, client := newFakePlaylist(t)
+
+	details := map[string]interface{}{
+		"name":          "Road trip",
+		"description":   "Songs for the drive",
+		"public":        false,
+		"collaborative": true,
+	}
+	if err := changePlaylistDetails(context.Background(), client, testPlaylistID, details); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(playlist.details) != 1 {
+		t.Fatalf("Expected a single request, got %d", len(playlist.details))
+	}
+	if !reflect.DeepEqual(playlist.details[0], details) {
+		t.Errorf("Expected %v to be sent, got %v", details, playlist.details[0])
+	}
+}
+
+func TestPlaylistDeleteOnDestroy(t *testing.T) {
+	tracks := []string{testTrackA, testTrackB}
+
+	tests := []struct {
+		onDestroy string
+		// remaining are the items left in the playlist
+		remaining  []string
+		unfollowed bool
+		// details are the playlist details changes expected
+		details []map[string]interface{}
+	}{
+		{
+			onDestroy:  playlistOnDestroyUnfollow,
+			remaining:  []string{"spotify:track:" + testTrackA, "spotify:track:" + testTrackB},
+			unfollowed: true,
+		},
+		{
+			onDestroy:  playlistOnDestroyClearAndUnfollow,
+			unfollowed: true,
+			details:    []map[string]interface{}{{"public": false}},
+		},
+		{
+			onDestroy: playlistOnDestroyRetain,
+			remaining: []string{"spotify:track:" + testTrackA, "spotify:track:" + testTrackB},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.onDestroy, func(t *testing.T) {
+			playlist, client := newFakePlaylist(t, tracks...)
+
+			d := schema.TestResourceDataRaw(t, resourceSpotifyPlaylist().Schema, map[string]interface{}{
+				"name":       testPlaylistName,
+				"on_destroy": tt.onDestroy,
+			})
+			d.SetId(testPlaylistID)
+
+			if diags := resourceSpotifyPlaylistDelete(context.Background(), d, client); diags.HasError() {
+				t.Fatalf("Expected no error, got %v", diags)
+			}
+
+			if got := playlist.items(); !reflect.DeepEqual(got, tt.remaining) {
+				t.Errorf("Expected %v to be left in the playlist, got %v", tt.remaining, got)
+			}
+			if playlist.unfollowed != tt.unfollowed {
+				t.Errorf("Expected unfollowed=%v, got %v", tt.unfollowed, playlist.unfollowed)
+			}
+			if !reflect.DeepEqual(playlist.details, tt.details) {
+				t.Errorf("Expected details changes %v, got %v", tt.details, playlist.details)
+			}
+		})
+	}
+}
+
+func TestPlaylistReadSetsOnDestroyMissingFromState(t *testing.T) {
+	_, client := newFakePlaylist(t, testTrackA)
+	resource := resourceSpotifyPlaylist()
+
+	// State written before on_destroy existed
+	state := &terraform.InstanceState{
+		ID: testPlaylistID,
+		Attributes: map[string]string{
+			"id":            testPlaylistID,
+			"name":          testPlaylistName,
+			"public":        "false",
+			"collaborative": "false",
+			"tracks.#":      "1",
+			"tracks.0":      "spotify:track:" + testTrackA,
+		},
+	}
+
+	state, diags := resource.RefreshWithoutUpgrade(context.Background(), state, client)
+	if diags.HasError() {
+		t.Fatalf("Expected the playlist to be read, got %v", diags)
+	}
+	if got := state.Attributes["on_destroy"]; got != playlistOnDestroyUnfollow {
+		t.Errorf("Expected on_destroy to be set to the default, got %q", got)
+	}
+
+	_, diff := planResource(t, resource, state, map[string]interface{}{"name": testPlaylistName}, client)
+	if !diff.Empty() {
+		t.Errorf("Expected no changes, got %v", diff.Attributes)
+	}
+}
+
+func TestPlaylistPlanUnsetAndEmptyTracks(t *testing.T) {
+	resource := resourceSpotifyPlaylist()
+	state := &terraform.InstanceState{
+		ID: testPlaylistID,
+		Attributes: map[string]string{
+			"id":            testPlaylistID,
+			"name":          testPlaylistName,
+			"public":        "false",
+			"collaborative": "false",
+			"on_destroy":    playlistOnDestroyUnfollow,
+			"tracks.#":      "2",
+			"tracks.0":      "spotify:track:" + testTrackA,
+			"tracks.1":      "spotify:track:" + testTrackB,
+		},
+	}
+
+	// Leaving tracks unset leaves the items alone
+	_, diff := planResource(t, resource, state, map[string]interface{}{"name": testPlaylistName}, nil)
+	if !diff.Empty() {
+		t.Errorf("Expected no changes with tracks unset, got %v", diff.Attributes)
+	}
+
+	// An empty list removes every item
+	_, diff = planResource(t, resource, state, map[string]interface{}{"name": testPlaylistName, "tracks": []interface{}{}}, nil)
+	if attr := diff.Attributes["tracks.#"]; attr == nil || attr.New != "0" {
+		t.Errorf("Expected the items to be removed with tracks = [], got %v", diff.Attributes)
+	}
+}
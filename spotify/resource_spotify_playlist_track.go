@@ -3,11 +3,16 @@ package spotify
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"github.com/zmb3/spotify/v2"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/spotifyuri"
 )
 
 func resourceSpotifyPlaylistTrack() *schema.Resource {
@@ -16,6 +21,10 @@ func resourceSpotifyPlaylistTrack() *schema.Resource {
 		ReadContext:   resourceSpotifyPlaylistTrackRead,
 		UpdateContext: resourceSpotifyPlaylistTrackUpdate,
 		DeleteContext: resourceSpotifyPlaylistTrackDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceSpotifyPlaylistTrackImport,
+		},
+		CustomizeDiff: customizeDiffRequireScopes("spotify_playlist_track"),
 		Schema: map[string]*schema.Schema{
 			"playlist_id": {
 				Type:        schema.TypeString,
@@ -24,15 +33,26 @@ func resourceSpotifyPlaylistTrack() *schema.Resource {
 				Description: "The ID of the playlist",
 			},
 			"track_id": {
-				Type:        schema.TypeString,
-				Required:    true,
-				ForceNew:    true,
-				Description: "The ID of the track",
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				DiffSuppressFunc: suppressEquivalentTrackID,
+				Description:      "The ID, spotify:track: URI or open.spotify.com link of the track",
+			},
+			"occurrence": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "Which occurrence of the track in the playlist this resource manages, counting from 0 in playlist order. Use distinct values when the same track is in the playlist more than once.",
 			},
 			"position": {
-				Type:        schema.TypeInt,
-				Optional:    true,
-				Description: "The position of the track in the playlist (0-based index)",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "The position of the track in the playlist (0-based index). Defaults to the end of the playlist; positions beyond the end also mean the end.",
 			},
 			"added_at": {
 				Type:        schema.TypeString,
@@ -69,32 +89,53 @@ func resourceSpotifyPlaylistTrack() *schema.Resource {
 }
 
 func resourceSpotifyPlaylistTrackCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	client := m.(*ProviderClient).SpotifyClient
+	if diags := requireUserAuth(m, "spotify_playlist_track"); diags != nil {
+		return diags
+	}
 
-	playlistID := spotify.ID(d.Get("playlist_id").(string))
-	trackID := spotify.ID(d.Get("track_id").(string))
-	position := d.Get("position").(int)
+	client := m.(*ProviderClient)
 
-	// Add the track to the playlist
+	playlistID, trackID, err := playlistTrackIDs(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
 	trackURI := fmt.Sprintf("spotify:track:%s", trackID)
-	if position > 0 {
-		// Add at specific position
-		// Note: v2 API doesn't have AddTracksToPlaylistAtPosition, so we use AddTracksToPlaylist
-		// and then reorder if needed
-		_, err := client.AddTracksToPlaylist(ctx, playlistID, spotify.ID(trackURI))
-		if err != nil {
-			return diag.FromErr(fmt.Errorf("error adding track to playlist: %s", err))
-		}
+	occurrence := d.Get("occurrence").(int)
 
-	} else {
-		// Add to the end
-		_, err := client.AddTracksToPlaylist(ctx, playlistID, spotify.ID(trackURI))
-		if err != nil {
-			return diag.FromErr(fmt.Errorf("error adding track to playlist: %s", err))
-		}
+	unlock := client.playlistLocks.lock(playlistID)
+	defer unlock()
+
+	uris, _, err := getPlaylistItemURIs(ctx, client, playlistID)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error reading playlist items: %s", err))
+	}
+
+	// Insert at the configured position, or append when it is not set or
+	// beyond the end of the playlist
+	position := len(uris)
+	if !d.GetRawConfig().GetAttr("position").IsNull() && d.Get("position").(int) < len(uris) {
+		position = d.Get("position").(int)
 	}
 
-	d.SetId(fmt.Sprintf("%s:%s", playlistID, trackID))
+	if err := checkTrackInsert(uris, trackURI, occurrence, position); err != nil {
+		return diag.FromErr(fmt.Errorf("error adding track to playlist %s: %s", playlistID, err))
+	}
+
+	// Add the track to the playlist
+	insertAt := position
+	if position == len(uris) {
+		insertAt = -1
+	}
+	snapshotID, err := insertPlaylistItems(ctx, client, playlistID, []string{trackURI}, insertAt)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error adding track to playlist: %s", err))
+	}
+
+	if err := verifyTrackInsert(ctx, client, playlistID, trackURI, occurrence, position, snapshotID); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(playlistTrackID(playlistID, trackID, occurrence))
 
 	d.Set("added_at", time.Now().Format(time.RFC3339))
 
@@ -102,19 +143,39 @@ func resourceSpotifyPlaylistTrackCreate(ctx context.Context, d *schema.ResourceD
 }
 
 func resourceSpotifyPlaylistTrackRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := requireUserAuth(m, "spotify_playlist_track"); diags != nil {
+		return diags
+	}
+
 	var diags diag.Diagnostics
-	client := m.(*ProviderClient).SpotifyClient
+	client := m.(*ProviderClient)
 
-	playlistID := spotify.ID(d.Get("playlist_id").(string))
-	trackID := spotify.ID(d.Get("track_id").(string))
+	playlistID, trackID, err := playlistTrackIDs(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
 
-	// Get the track details
-	track, err := client.GetTrack(ctx, trackID)
+	// Find the track occurrence in the playlist. Items are shared with the
+	// other resources reading the playlist at the same snapshot.
+	items, _, err := getPlaylistItems(ctx, client, playlistID)
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("error getting track: %s", err))
+		return diag.FromErr(fmt.Errorf("error checking track in playlist: %s", err))
+	}
+
+	occurrence := d.Get("occurrence").(int)
+	item, position := findTrackOccurrence(items, trackID, occurrence)
+	if item == nil {
+		// Track is no longer in the playlist
+		d.SetId("")
+		return diags
 	}
 
+	// States written before occurrence existed manage the first occurrence
+	// but have no value for it, which would plan a replacement
+	d.Set("occurrence", occurrence)
+
 	// Set the track details
+	track := item.Track.Track
 	d.Set("track_name", track.Name)
 	if len(track.Artists) > 0 {
 		d.Set("artist", track.Artists[0].Name)
@@ -122,59 +183,126 @@ func resourceSpotifyPlaylistTrackRead(ctx context.Context, d *schema.ResourceDat
 	d.Set("album", track.Album.Name)
 	d.Set("duration_ms", track.Duration)
 
-	// Check if the track is still in the playlist
-	trackExists, position, err := checkTrackInPlaylist(ctx, client, playlistID, trackID)
-	if err != nil {
-		return diag.FromErr(fmt.Errorf("error checking track in playlist: %s", err))
+	// Report other resources managing the same occurrence of the track. Only
+	// resources read by this provider instance are counted, see trackClaims.
+	key := trackClaimKey(string(playlistID), string(trackID), occurrence)
+	if others := client.trackClaims.claim(key); others > 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Playlist track managed by more than one resource",
+			Detail: fmt.Sprintf("Occurrence %d of track %s in playlist %s is managed by %d spotify_playlist_track resources with the ID %s. "+
+				"Each resource removes and moves the same item, set a distinct occurrence on each of them.",
+				occurrence, trackID, playlistID, others+1, d.Id()),
+		})
 	}
 
-	if !trackExists {
-		// Track is no longer in the playlist
-		d.SetId("")
-		return diags
+	// Update position if it's different. A position beyond the end of the
+	// playlist means the last position, keep it while the track is last.
+	if configured := d.Get("position").(int); position != len(items)-1 || configured < position {
+		d.Set("position", position)
+	}
+	if item.AddedAt != "" {
+		d.Set("added_at", item.AddedAt)
 	}
-
-	// Update position if it's different
-	d.Set("position", position)
 
 	return diags
 }
 
 func resourceSpotifyPlaylistTrackUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := requireUserAuth(m, "spotify_playlist_track"); diags != nil {
+		return diags
+	}
+
 	client := m.(*ProviderClient).SpotifyClient
 
-	playlistID := spotify.ID(d.Get("playlist_id").(string))
-	trackID := spotify.ID(d.Get("track_id").(string))
+	playlistID, trackID, err := playlistTrackIDs(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
 
 	// Only position can be updated
 	if d.HasChange("position") {
-		// First, remove the track
-		_, err := client.RemoveTracksFromPlaylist(ctx, playlistID, trackID)
+		unlock := m.(*ProviderClient).playlistLocks.lock(playlistID)
+		defer unlock()
+
+		uris, snapshotID, err := getPlaylistItemURIs(ctx, m.(*ProviderClient), playlistID)
 		if err != nil {
-			return diag.FromErr(fmt.Errorf("error removing track from playlist: %s", err))
+			return diag.FromErr(fmt.Errorf("error reading playlist items: %s", err))
 		}
 
-		// Then add it back at the new position
+		occurrence := d.Get("occurrence").(int)
 		trackURI := fmt.Sprintf("spotify:track:%s", trackID)
-		_, err = client.AddTracksToPlaylist(ctx, playlistID, spotify.ID(trackURI))
-		if err != nil {
-			return diag.FromErr(fmt.Errorf("error adding track to playlist at new position: %s", err))
+		from := nthOccurrence(uris, trackURI, occurrence)
+		if from < 0 {
+			return diag.FromErr(fmt.Errorf("occurrence %d of track %s is no longer in playlist %s", occurrence, trackID, playlistID))
+		}
+
+		// Positions beyond the end of the playlist mean the last position
+		to := d.Get("position").(int)
+		if to > len(uris)-1 {
+			to = len(uris) - 1
+		}
+
+		if err := checkTrackMove(uris, trackURI, from, to); err != nil {
+			return diag.FromErr(fmt.Errorf("error moving track in playlist %s: %s", playlistID, err))
+		}
+
+		if to != from {
+			// insert_before refers to positions before the track is moved
+			insertBefore := to
+			if to > from {
+				insertBefore = to + 1
+			}
+
+			_, err := client.ReorderPlaylistTracks(ctx, playlistID, spotify.PlaylistReorderOptions{
+				RangeStart:   spotify.Numeric(from),
+				RangeLength:  1,
+				InsertBefore: spotify.Numeric(insertBefore),
+				SnapshotID:   snapshotID,
+			})
+			if err != nil {
+				return diag.FromErr(fmt.Errorf("error moving track to position %d: %s", to, err))
+			}
 		}
-	
 	}
 
 	return resourceSpotifyPlaylistTrackRead(ctx, d, m)
 }
 
 func resourceSpotifyPlaylistTrackDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := requireUserAuth(m, "spotify_playlist_track"); diags != nil {
+		return diags
+	}
+
 	var diags diag.Diagnostics
 	client := m.(*ProviderClient).SpotifyClient
 
-	playlistID := spotify.ID(d.Get("playlist_id").(string))
-	trackID := spotify.ID(d.Get("track_id").(string))
+	playlistID, trackID, err := playlistTrackIDs(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	unlock := m.(*ProviderClient).playlistLocks.lock(playlistID)
+	defer unlock()
 
-	// Remove the track from the playlist
-	_, err := client.RemoveTracksFromPlaylist(ctx, playlistID, trackID)
+	// Remove only the managed occurrence, by position. The snapshot ID makes
+	// Spotify interpret the position against the playlist as it was read.
+	uris, snapshotID, err := getPlaylistItemURIs(ctx, m.(*ProviderClient), playlistID)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("error reading playlist items: %s", err))
+	}
+
+	trackURI := fmt.Sprintf("spotify:track:%s", trackID)
+	position := nthOccurrence(uris, trackURI, d.Get("occurrence").(int))
+	if position < 0 {
+		// Already gone
+		d.SetId("")
+		return diags
+	}
+
+	_, err = client.RemoveTracksFromPlaylistOpt(ctx, playlistID, []spotify.TrackToRemove{
+		{URI: trackURI, Positions: []int{position}},
+	}, snapshotID)
 	if err != nil {
 		return diag.FromErr(fmt.Errorf("error removing track from playlist: %s", err))
 	}
@@ -184,31 +312,145 @@ func resourceSpotifyPlaylistTrackDelete(ctx context.Context, d *schema.ResourceD
 	return diags
 }
 
-// Helper function to check if a track is in a playlist and get its position
-func checkTrackInPlaylist(ctx context.Context, client *spotify.Client, playlistID, trackID spotify.ID) (bool, int, error) {
-	limit := 100
-	offset := 0
-	position := 0
+// resourceSpotifyPlaylistTrackImport accepts a playlist_id:track_id composite
+// ID, with an optional :occurrence suffix for duplicate tracks
+func resourceSpotifyPlaylistTrackImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	parts := strings.Split(d.Id(), ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, fmt.Errorf("unexpected import ID %q, expected playlist_id:track_id or playlist_id:track_id:occurrence", d.Id())
+	}
 
-	for {
-		tracksPage, err := client.GetPlaylistItems(ctx, playlistID, spotify.Limit(limit), spotify.Offset(offset))
-		if err != nil {
-			return false, 0, err
-		}
+	playlistID, err := spotifyuri.ParseID(parts[0], spotifyuri.Playlist)
+	if err != nil {
+		return nil, fmt.Errorf("error importing playlist track: %s", err)
+	}
+	trackID, err := spotifyuri.ParseID(parts[1], spotifyuri.Track)
+	if err != nil {
+		return nil, fmt.Errorf("error importing playlist track: %s", err)
+	}
 
-		for i, item := range tracksPage.Items {
-			if item.Track.Track != nil && item.Track.Track.ID == trackID {
-				return true, offset + i, nil
-			}
+	occurrence := 0
+	if len(parts) == 3 {
+		occurrence, err = strconv.Atoi(parts[2])
+		if err != nil || occurrence < 0 {
+			return nil, fmt.Errorf("error importing playlist track: invalid occurrence %q", parts[2])
 		}
+	}
+
+	d.Set("playlist_id", playlistID)
+	d.Set("track_id", trackID)
+	d.Set("occurrence", occurrence)
+	d.SetId(playlistTrackID(spotify.ID(playlistID), spotify.ID(trackID), occurrence))
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// playlistTrackID returns the resource ID: playlist_id:track_id, with an
+// :occurrence suffix for occurrences after the first
+func playlistTrackID(playlistID, trackID spotify.ID, occurrence int) string {
+	if occurrence > 0 {
+		return fmt.Sprintf("%s:%s:%d", playlistID, trackID, occurrence)
+	}
+	return fmt.Sprintf("%s:%s", playlistID, trackID)
+}
+
+// playlistTrackIDs returns the playlist ID and the track ID, which may be
+// configured as a bare ID, a spotify:track: URI or an open.spotify.com link
+func playlistTrackIDs(d *schema.ResourceData) (spotify.ID, spotify.ID, error) {
+	trackID, err := spotifyuri.ParseID(d.Get("track_id").(string), spotifyuri.Track)
+	if err != nil {
+		return "", "", err
+	}
+	return spotify.ID(d.Get("playlist_id").(string)), spotify.ID(trackID), nil
+}
 
-		if len(tracksPage.Items) < limit {
-			break
+// countItem returns how often uri occurs in uris
+func countItem(uris []string, uri string) int {
+	count := 0
+	for _, u := range uris {
+		if u == uri {
+			count++
 		}
+	}
+	return count
+}
 
-		offset += limit
-		position += len(tracksPage.Items)
+// checkTrackInsert checks that a copy of the track inserted at position
+// becomes the given occurrence. Inserting it before an existing copy would
+// renumber that copy and the ones after it, which other resources may manage.
+func checkTrackInsert(uris []string, uri string, occurrence, position int) error {
+	before, after := countItem(uris[:position], uri), countItem(uris[position:], uri)
+	if after > 0 {
+		return fmt.Errorf("inserting %s at position %d puts it before occurrence %d of the track, "+
+			"which would renumber the copies after it. Copies of a track keep their order, insert it after them", uri, position, before)
+	}
+	if before != occurrence {
+		return fmt.Errorf("inserting %s at position %d makes it occurrence %d of the track, not occurrence %d. "+
+			"Set occurrence to %d, or create the resources managing earlier copies first, e.g. with depends_on", uri, position, before, occurrence, before)
+	}
+	return nil
+}
+
+// verifyTrackInsert checks that the copy inserted at position is the given
+// occurrence. Spotify does not apply inserts against a snapshot, so another
+// edit may have changed the playlist in between; the copy is then removed
+// again, by position against the snapshot the insert returned.
+func verifyTrackInsert(ctx context.Context, client *ProviderClient, playlistID spotify.ID, uri string, occurrence, position int, snapshotID string) error {
+	uris, _, err := getPlaylistItemURIs(ctx, client, playlistID)
+	if err != nil {
+		return fmt.Errorf("error reading playlist items after adding track: %s", err)
+	}
+	if position < len(uris) && uris[position] == uri && countItem(uris[:position], uri) == occurrence {
+		return nil
 	}
 
-	return false, 0, nil
-}
\ No newline at end of file
+	_, err = client.SpotifyClient.RemoveTracksFromPlaylistOpt(ctx, playlistID, []spotify.TrackToRemove{
+		{URI: uri, Positions: []int{position}},
+	}, snapshotID)
+	if err != nil {
+		return fmt.Errorf("playlist %s changed while %s was added, so it is not occurrence %d, and removing it again failed: %s", playlistID, uri, occurrence, err)
+	}
+	return fmt.Errorf("playlist %s changed while %s was added, so it is not occurrence %d. It was removed again, retry the apply", playlistID, uri, occurrence)
+}
+
+// checkTrackMove checks that moving the copy of the track at from to position
+// to keeps it the same occurrence. Passing another copy would swap which
+// resource manages which copy.
+func checkTrackMove(uris []string, uri string, from, to int) error {
+	rest := append(append([]string(nil), uris[:from]...), uris[from+1:]...)
+	occurrence, moved := countItem(uris[:from], uri), countItem(rest[:to], uri)
+	if moved != occurrence {
+		return fmt.Errorf("moving occurrence %d of %s to position %d would make it occurrence %d of the track. "+
+			"Copies of a track keep their order, change the positions of the other copies too", occurrence, uri, to, moved)
+	}
+	return nil
+}
+
+// suppressEquivalentTrackID hides differences between forms of the same
+// track, such as the bare ID stored by import and a URI in the configuration
+func suppressEquivalentTrackID(k, old, new string, d *schema.ResourceData) bool {
+	oldID, err := spotifyuri.ParseID(old, spotifyuri.Track)
+	if err != nil {
+		return false
+	}
+	newID, err := spotifyuri.ParseID(new, spotifyuri.Track)
+	if err != nil {
+		return false
+	}
+	return oldID == newID
+}
+
+// findTrackOccurrence returns the n-th (0-based) occurrence of the track in
+// the playlist items and its position, or a nil item if there are fewer
+func findTrackOccurrence(items []spotify.PlaylistItem, trackID spotify.ID, occurrence int) (*spotify.PlaylistItem, int) {
+	for i, item := range items {
+		if item.Track.Track == nil || item.Track.Track.ID != trackID {
+			continue
+		}
+		if occurrence == 0 {
+			return &items[i], i
+		}
+		occurrence--
+	}
+	return nil, -1
+}
@@ -0,0 +1,179 @@
+package spotify
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/zmb3/spotify/v2"
+)
+
+// playlistTrackData returns a spotify_playlist_track resource managing the
+// given occurrence of a track in the test playlist, with any other
+// configuration given in extra
+func playlistTrackData(t *testing.T, trackID string, occurrence int, extra map[string]interface{}) *schema.ResourceData {
+	raw := map[string]interface{}{
+		"playlist_id": testPlaylistID,
+		"track_id":    trackID,
+		"occurrence":  occurrence,
+	}
+	for k, v := range extra {
+		raw[k] = v
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceSpotifyPlaylistTrack().Schema, raw)
+	d.SetId(playlistTrackID(testPlaylistID, spotify.ID(trackID), occurrence))
+	return d
+}
+
+func hasWarning(diags diag.Diagnostics) bool {
+	for _, d := range diags {
+		if d.Severity == diag.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPlaylistTrackReadWarnsAboutDuplicateClaims(t *testing.T) {
+	_, client := newFakePlaylist(t, testTrackA, testTrackB, testTrackA)
+
+	// Two resources managing the same occurrence share their ID
+	first, second := playlistTrackData(t, testTrackA, 0, nil), playlistTrackData(t, testTrackA, 0, nil)
+	other := playlistTrackData(t, testTrackA, 1, nil)
+
+	if diags := resourceSpotifyPlaylistTrackRead(context.Background(), first, client); diags.HasError() || hasWarning(diags) {
+		t.Fatalf("Expected the first resource to read cleanly, got %v", diags)
+	}
+	if diags := resourceSpotifyPlaylistTrackRead(context.Background(), other, client); diags.HasError() || hasWarning(diags) {
+		t.Fatalf("Expected another occurrence to read cleanly, got %v", diags)
+	}
+	if diags := resourceSpotifyPlaylistTrackRead(context.Background(), second, client); !hasWarning(diags) {
+		t.Errorf("Expected a warning for the second resource claiming occurrence 0, got %v", diags)
+	}
+}
+
+func TestPlaylistTrackReadSetsOccurrenceMissingFromState(t *testing.T) {
+	_, client := newFakePlaylist(t, testTrackA, testTrackB)
+	resource := resourceSpotifyPlaylistTrack()
+
+	// State written before occurrence existed
+	id := testPlaylistID + ":" + testTrackA
+	state := &terraform.InstanceState{
+		ID: id,
+		Attributes: map[string]string{
+			"id":          id,
+			"playlist_id": testPlaylistID,
+			"track_id":    testTrackA,
+			"position":    "0",
+		},
+	}
+
+	state, diags := resource.RefreshWithoutUpgrade(context.Background(), state, client)
+	if diags.HasError() {
+		t.Fatalf("Expected the track to be read, got %v", diags)
+	}
+	if got := state.Attributes["occurrence"]; got != "0" {
+		t.Errorf("Expected occurrence 0 in state, got %q", got)
+	}
+
+	_, diff := planResource(t, resource, state, map[string]interface{}{
+		"playlist_id": testPlaylistID,
+		"track_id":    testTrackA,
+		"position":    0,
+	}, client)
+	if diff.RequiresNew() || !diff.Empty() {
+		t.Errorf("Expected no changes, got %v", diff.Attributes)
+	}
+}
+
+func TestCheckTrackInsert(t *testing.T) {
+	a, b := "spotify:track:"+testTrackA, "spotify:track:"+testTrackB
+	uris := []string{a, b}
+
+	tests := []struct {
+		occurrence int
+		position   int
+		valid      bool
+	}{
+		{1, 2, true},  // after the existing copy
+		{1, 1, true},  // right after it
+		{0, 2, false}, // would become occurrence 1
+		{0, 0, false}, // would renumber the existing copy
+		{2, 2, false}, // there is only one earlier copy
+	}
+
+	for _, tt := range tests {
+		err := checkTrackInsert(uris, a, tt.occurrence, tt.position)
+		if (err == nil) != tt.valid {
+			t.Errorf("checkTrackInsert(occurrence %d, position %d) = %v, expected valid=%v", tt.occurrence, tt.position, err, tt.valid)
+		}
+	}
+}
+
+func TestCheckTrackMove(t *testing.T) {
+	a, b := "spotify:track:"+testTrackA, "spotify:track:"+testTrackB
+	uris := []string{a, b, b, a}
+
+	if err := checkTrackMove(uris, a, 0, 2); err != nil {
+		t.Errorf("Expected a move before the next copy to be valid, got %v", err)
+	}
+	if err := checkTrackMove(uris, a, 0, 3); err == nil {
+		t.Error("Expected a move past the next copy to be rejected")
+	}
+	if err := checkTrackMove(uris, a, 3, 0); err == nil {
+		t.Error("Expected a move before the previous copy to be rejected")
+	}
+}
+
+func TestPlaylistTrackUpdateKeepsCopiesInOrder(t *testing.T) {
+	playlist, client := newFakePlaylist(t, testTrackA, testTrackB, testTrackA)
+	before := playlist.items()
+
+	d := playlistTrackData(t, testTrackA, 0, map[string]interface{}{"position": 2})
+	if diags := resourceSpotifyPlaylistTrackUpdate(context.Background(), d, client); !diags.HasError() {
+		t.Fatal("Expected moving occurrence 0 past occurrence 1 to fail")
+	}
+	if got := playlist.items(); !reflect.DeepEqual(got, before) {
+		t.Errorf("Expected the playlist to be unchanged, got %v", got)
+	}
+
+	d = playlistTrackData(t, testTrackA, 0, map[string]interface{}{"position": 1})
+	if diags := resourceSpotifyPlaylistTrackUpdate(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("Expected the move to succeed, got %v", diags)
+	}
+	expected := []string{"spotify:track:" + testTrackB, "spotify:track:" + testTrackA, "spotify:track:" + testTrackA}
+	if got := playlist.items(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestVerifyTrackInsertRemovesMisplacedCopy(t *testing.T) {
+	a := "spotify:track:" + testTrackA
+	playlist, client := newFakePlaylist(t, testTrackB)
+
+	snapshotID, err := insertPlaylistItems(context.Background(), client, testPlaylistID, []string{a}, -1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := verifyTrackInsert(context.Background(), client, testPlaylistID, a, 0, 1, snapshotID); err != nil {
+		t.Fatalf("Expected the appended copy to be occurrence 0, got %v", err)
+	}
+
+	// Another copy lands in front of the one being verified
+	snapshotID, err = insertPlaylistItems(context.Background(), client, testPlaylistID, []string{a}, -1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	err = verifyTrackInsert(context.Background(), client, testPlaylistID, a, 0, 2, snapshotID)
+	if err == nil {
+		t.Fatal("Expected a copy that is not occurrence 0 to be rejected")
+	}
+	expected := []string{"spotify:track:" + testTrackB, a}
+	if got := playlist.items(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected the misplaced copy to be removed, got %v", got)
+	}
+}
@@ -0,0 +1,300 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/zmb3/spotify/v2"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/logging"
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/reconcile"
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/spotifyuri"
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/utils"
+)
+
+// Values of the mode argument of spotify_playlist_tracks
+const (
+	playlistTracksModeExclusive = "exclusive"
+	playlistTracksModeAdditive  = "additive"
+)
+
+func resourceSpotifyPlaylistTracks() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceSpotifyPlaylistTracksCreate,
+		ReadContext:   resourceSpotifyPlaylistTracksRead,
+		UpdateContext: resourceSpotifyPlaylistTracksUpdate,
+		DeleteContext: resourceSpotifyPlaylistTracksDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceSpotifyPlaylistTracksImport,
+		},
+		CustomizeDiff: customdiff.All(
+			customizeDiffRequireScopes("spotify_playlist_tracks"),
+			customdiff.ComputedIf("item_details", playlistTracksChanged),
+			customdiff.ComputedIf("snapshot_id", playlistTracksChanged),
+		),
+		Schema: map[string]*schema.Schema{
+			"playlist_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The Spotify ID of the playlist",
+			},
+			"items": {
+				Type:        schema.TypeList,
+				Required:    true,
+				Description: "The ordered items to manage: track IDs, spotify:track:, spotify:episode: or spotify:local: URIs, or open.spotify.com links. Stored as URIs.",
+				Elem: &schema.Schema{
+					Type:             schema.TypeString,
+					ValidateFunc:     validatePlaylistItem,
+					DiffSuppressFunc: suppressEquivalentPlaylistItem,
+				},
+			},
+			"mode": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  playlistTracksModeExclusive,
+				ValidateFunc: validation.StringInSlice([]string{
+					playlistTracksModeExclusive,
+					playlistTracksModeAdditive,
+				}, false),
+				Description: "Whether items is the whole content of the playlist, in order (exclusive, default), or only needs to be in it, leaving other items alone (additive)",
+			},
+			"item_details": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The managed items as found in the playlist",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"uri": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The URI of the item",
+						},
+						"position": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The position of the item in the playlist (0-based index)",
+						},
+						"added_at": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The timestamp when the item was added to the playlist",
+						},
+						"added_by": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The Spotify user ID of the user who added the item",
+						},
+					},
+				},
+			},
+			"snapshot_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The Spotify snapshot ID of the playlist",
+			},
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
+	}
+}
+
+func resourceSpotifyPlaylistTracksCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := requireUserAuth(m, "spotify_playlist_tracks"); diags != nil {
+		return diags
+	}
+
+	playlistID := spotify.ID(d.Get("playlist_id").(string))
+
+	items, err := expandPlaylistItems(d.Get("items").([]interface{}))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	logging.DefaultLogger.WithContext(ctx).Info("Managing playlist items",
+		"playlist_id", string(playlistID),
+		"mode", d.Get("mode").(string),
+		"item_count", len(items),
+	)
+
+	if err := editPlaylistTracks(ctx, m.(*ProviderClient), playlistID, d.Get("mode").(string), nil, items); err != nil {
+		return diag.FromErr(fmt.Errorf("error editing items of playlist %s: %s", playlistID, err))
+	}
+
+	d.SetId(string(playlistID))
+
+	return resourceSpotifyPlaylistTracksRead(ctx, d, m)
+}
+
+func resourceSpotifyPlaylistTracksRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := requireUserAuth(m, "spotify_playlist_tracks"); diags != nil {
+		return diags
+	}
+
+	var diags diag.Diagnostics
+	playlistID := spotify.ID(d.Id())
+
+	items, snapshotID, err := getPlaylistItems(ctx, m.(*ProviderClient), playlistID)
+	if err != nil {
+		if utils.IsSpotifyNotFoundError(err) {
+			logging.DefaultLogger.WithContext(ctx).Warn("Playlist not found, removing from state", "playlist_id", d.Id())
+			d.SetId("")
+			return diags
+		}
+		return utils.HandleAPIError(ctx, err, "read items of", "playlist", d.Id())
+	}
+
+	current := playlistItemURIs(items)
+
+	// In exclusive mode every item is managed, in additive mode only the
+	// managed items still found in the playlist are kept in state
+	var managed, positions []int
+	if d.Get("mode").(string) == playlistTracksModeAdditive {
+		previous, err := expandPlaylistItems(d.Get("items").([]interface{}))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		positions = reconcile.Match(current, previous)
+	} else {
+		positions = make([]int, len(current))
+		for i := range current {
+			positions[i] = i
+		}
+	}
+	for _, position := range positions {
+		if position < 0 {
+			continue
+		}
+		if current[position] == "" {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Unavailable playlist item",
+				Detail:   fmt.Sprintf("The item at position %d of playlist %s is unavailable and is left out of items.", position, playlistID),
+			})
+			continue
+		}
+		managed = append(managed, position)
+	}
+
+	uris := make([]string, len(managed))
+	details := make([]interface{}, len(managed))
+	for i, position := range managed {
+		item := items[position]
+		uris[i] = current[position]
+		details[i] = map[string]interface{}{
+			"uri":      current[position],
+			"position": position,
+			"added_at": item.AddedAt,
+			"added_by": item.AddedBy.ID,
+		}
+	}
+
+	d.Set("playlist_id", string(playlistID))
+	d.Set("items", uris)
+	d.Set("item_details", details)
+	d.Set("snapshot_id", snapshotID)
+
+	return diags
+}
+
+func resourceSpotifyPlaylistTracksUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := requireUserAuth(m, "spotify_playlist_tracks"); diags != nil {
+		return diags
+	}
+
+	playlistID := spotify.ID(d.Id())
+
+	if d.HasChanges("items", "mode") {
+		oldItems, newItems := d.GetChange("items")
+		previous, err := expandPlaylistItems(oldItems.([]interface{}))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		desired, err := expandPlaylistItems(newItems.([]interface{}))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		if err := editPlaylistTracks(ctx, m.(*ProviderClient), playlistID, d.Get("mode").(string), previous, desired); err != nil {
+			return diag.FromErr(fmt.Errorf("error editing items of playlist %s: %s", playlistID, err))
+		}
+	}
+
+	return resourceSpotifyPlaylistTracksRead(ctx, d, m)
+}
+
+func resourceSpotifyPlaylistTracksDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if diags := requireUserAuth(m, "spotify_playlist_tracks"); diags != nil {
+		return diags
+	}
+
+	var diags diag.Diagnostics
+	playlistID := spotify.ID(d.Id())
+
+	previous, err := expandPlaylistItems(d.Get("items").([]interface{}))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// Remove the managed items: all of them in exclusive mode
+	err = editPlaylistTracks(ctx, m.(*ProviderClient), playlistID, d.Get("mode").(string), previous, nil)
+	if err != nil && !utils.IsSpotifyNotFoundError(err) {
+		return diag.FromErr(fmt.Errorf("error removing items from playlist %s: %s", playlistID, err))
+	}
+
+	d.SetId("")
+
+	return diags
+}
+
+// resourceSpotifyPlaylistTracksImport accepts a playlist ID, URI or URL and
+// imports the playlist's items in exclusive mode
+func resourceSpotifyPlaylistTracksImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	playlistID, err := spotifyuri.ParseID(d.Id(), spotifyuri.Playlist)
+	if err != nil {
+		return nil, fmt.Errorf("error importing playlist tracks: %s", err)
+	}
+
+	// Defaults are not applied on import, set them so the next plan is clean
+	d.Set("mode", playlistTracksModeExclusive)
+	d.Set("playlist_id", playlistID)
+	d.SetId(playlistID)
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// editPlaylistTracks edits the playlist so it holds desired: as its whole
+// content in exclusive mode, or next to the items it already has in additive
+// mode, where previous is the list managed so far
+func editPlaylistTracks(ctx context.Context, client *ProviderClient, playlistID spotify.ID, mode string, previous, desired []string) error {
+	// Other resources editing the playlist in the same apply would shift the
+	// positions read here
+	unlock := client.playlistLocks.lock(playlistID)
+	defer unlock()
+
+	current, snapshotID, err := getPlaylistItemURIs(ctx, client, playlistID)
+	if err != nil {
+		return err
+	}
+
+	if mode == playlistTracksModeAdditive {
+		desired = reconcile.Additive(current, previous, desired)
+	}
+
+	_, err = applyPlaylistItems(ctx, client, playlistID, current, snapshotID, desired)
+	return err
+}
+
+// playlistTracksChanged reports whether the plan edits the playlist, which
+// changes the computed item details and snapshot ID
+func playlistTracksChanged(ctx context.Context, d *schema.ResourceDiff, m interface{}) bool {
+	return d.HasChange("items") || d.HasChange("mode")
+}
@@ -0,0 +1,131 @@
+package spotify
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// playlistTracksData returns a spotify_playlist_tracks resource managing the
+// given items of the test playlist in mode
+func playlistTracksData(t *testing.T, mode string, items ...string) *schema.ResourceData {
+	return schema.TestResourceDataRaw(t, resourceSpotifyPlaylistTracks().Schema, map[string]interface{}{
+		"playlist_id": testPlaylistID,
+		"mode":        mode,
+		"items":       interfaces(items),
+	})
+}
+
+// trackURIs returns the URIs of the given track IDs
+func trackURIs(trackIDs ...string) []string {
+	uris := make([]string, len(trackIDs))
+	for i, id := range trackIDs {
+		uris[i] = "spotify:track:" + id
+	}
+	return uris
+}
+
+func TestPlaylistTracksExclusiveMode(t *testing.T) {
+	other := testTrackURIs(0, 1)[0]
+	playlist, client := newFakePlaylistOf(t, []string{other, "spotify:track:" + testTrackA})
+	d := playlistTracksData(t, playlistTracksModeExclusive, testTrackB, testTrackA)
+
+	if diags := resourceSpotifyPlaylistTracksCreate(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("Expected no error, got %v", diags)
+	}
+
+	// Items that are not listed are removed, the listed ones are put in order
+	expected := trackURIs(testTrackB, testTrackA)
+	if got := playlist.items(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected the playlist to hold %v, got %v", expected, got)
+	}
+	if got := d.Get("items").([]interface{}); !reflect.DeepEqual(got, interfaces(expected)) {
+		t.Errorf("Expected items %v in state, got %v", expected, got)
+	}
+
+	if diags := resourceSpotifyPlaylistTracksDelete(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("Expected no error, got %v", diags)
+	}
+	if got := playlist.items(); len(got) != 0 {
+		t.Errorf("Expected destroying the resource to empty the playlist, got %v", got)
+	}
+}
+
+func TestPlaylistTracksAdditiveMode(t *testing.T) {
+	other := testTrackURIs(0, 1)[0]
+	playlist, client := newFakePlaylistOf(t, []string{other, "spotify:track:" + testTrackA})
+	d := playlistTracksData(t, playlistTracksModeAdditive, testTrackA, testTrackB)
+
+	if diags := resourceSpotifyPlaylistTracksCreate(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("Expected no error, got %v", diags)
+	}
+
+	// Other items are left alone, the copy of A already there counts, B is appended
+	expected := append([]string{other}, trackURIs(testTrackA, testTrackB)...)
+	if got := playlist.items(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected the playlist to hold %v, got %v", expected, got)
+	}
+	if got := d.Get("items").([]interface{}); !reflect.DeepEqual(got, interfaces(trackURIs(testTrackA, testTrackB))) {
+		t.Errorf("Expected only the managed items in state, got %v", got)
+	}
+	if len(playlist.removals) != 0 {
+		t.Errorf("Expected no removals, got %v", playlist.removals)
+	}
+}
+
+func TestPlaylistTracksAdditiveModeDestroy(t *testing.T) {
+	other := testTrackURIs(0, 1)[0]
+	playlist, client := newFakePlaylistOf(t, []string{other})
+	d := playlistTracksData(t, playlistTracksModeAdditive, testTrackA, testTrackB)
+
+	if diags := resourceSpotifyPlaylistTracksCreate(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("Expected no error, got %v", diags)
+	}
+	if diags := resourceSpotifyPlaylistTracksDelete(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("Expected no error, got %v", diags)
+	}
+
+	// Only the managed items are removed
+	if got := playlist.items(); !reflect.DeepEqual(got, []string{other}) {
+		t.Errorf("Expected only %s to be left, got %v", other, got)
+	}
+}
+
+func TestPlaylistTracksResourcesSharingPlaylist(t *testing.T) {
+	other := testTrackURIs(0, 1)[0]
+	playlist, client := newFakePlaylistOf(t, []string{other})
+	resources := []*schema.ResourceData{
+		playlistTracksData(t, playlistTracksModeAdditive, testTrackA),
+		playlistTracksData(t, playlistTracksModeAdditive, testTrackB),
+	}
+
+	// Terraform applies resources concurrently, the edits of each must not be
+	// sent against positions the other one shifted
+	apply := func(f func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics) {
+		var wg sync.WaitGroup
+		for _, d := range resources {
+			wg.Add(1)
+			go func(d *schema.ResourceData) {
+				defer wg.Done()
+				if diags := f(context.Background(), d, client); diags.HasError() {
+					t.Errorf("Expected no error, got %v", diags)
+				}
+			}(d)
+		}
+		wg.Wait()
+	}
+
+	apply(resourceSpotifyPlaylistTracksCreate)
+	if got := playlist.items(); len(got) != 3 || got[0] != other {
+		t.Errorf("Expected both tracks to be added after %s, got %v", other, got)
+	}
+
+	apply(resourceSpotifyPlaylistTracksDelete)
+	if got := playlist.items(); !reflect.DeepEqual(got, []string{other}) {
+		t.Errorf("Expected only %s to be left, got %v", other, got)
+	}
+}
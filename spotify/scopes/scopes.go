@@ -0,0 +1,91 @@
+// Package scopes describes the Spotify authorization scopes needed by each
+// resource and data source of the provider.
+package scopes
+
+import (
+	"sort"
+	"strings"
+)
+
+// Spotify authorization scopes used by the provider
+const (
+	UserReadPrivate        = "user-read-private"
+	UserTopRead            = "user-top-read"
+	UserReadRecentlyPlayed = "user-read-recently-played"
+	PlaylistModifyPublic   = "playlist-modify-public"
+	PlaylistModifyPrivate  = "playlist-modify-private"
+	UGCImageUpload         = "ugc-image-upload"
+)
+
+// Required lists the scopes each resource and data source needs. Types that
+// only read catalog data need no scopes and are not listed.
+var Required = map[string][]string{
+	"spotify_playlist":         {PlaylistModifyPublic, PlaylistModifyPrivate},
+	"spotify_playlist_track":   {PlaylistModifyPublic, PlaylistModifyPrivate},
+	"spotify_playlist_tracks":  {PlaylistModifyPublic, PlaylistModifyPrivate},
+	"spotify_playlist_cover":   {UGCImageUpload, PlaylistModifyPublic, PlaylistModifyPrivate},
+	"spotify_user":             {UserReadPrivate},
+	"spotify_user_preferences": {UserTopRead},
+}
+
+// Default is the scope list requested when authorizing the provider
+var Default = []string{
+	UGCImageUpload,
+	UserTopRead,
+	UserReadRecentlyPlayed,
+	UserReadPrivate,
+	PlaylistModifyPublic,
+	PlaylistModifyPrivate,
+}
+
+// Parse splits a space separated scope string, as returned in the token response
+func Parse(scope string) []string {
+	return strings.Fields(scope)
+}
+
+// Missing returns the required scopes that are not in the granted list
+func Missing(granted, required []string) []string {
+	grantedSet := make(map[string]bool, len(granted))
+	for _, scope := range granted {
+		grantedSet[scope] = true
+	}
+
+	var missing []string
+	for _, scope := range required {
+		if !grantedSet[scope] {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// All returns every scope required by any resource or data source, sorted
+func All() []string {
+	seen := map[string]bool{}
+	var all []string
+	for _, required := range Required {
+		for _, scope := range required {
+			if !seen[scope] {
+				seen[scope] = true
+				all = append(all, scope)
+			}
+		}
+	}
+	sort.Strings(all)
+	return all
+}
+
+// RequiredBy returns the sorted names of the resources and data sources that need the given scope
+func RequiredBy(scope string) []string {
+	var names []string
+	for name, required := range Required {
+		for _, s := range required {
+			if s == scope {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,41 @@
+package scopes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	parsed := Parse("playlist-modify-public  ugc-image-upload ")
+	expected := []string{"playlist-modify-public", "ugc-image-upload"}
+
+	if !reflect.DeepEqual(parsed, expected) {
+		t.Errorf("Expected %v, got %v", expected, parsed)
+	}
+}
+
+func TestMissing(t *testing.T) {
+	granted := []string{PlaylistModifyPublic, PlaylistModifyPrivate}
+
+	missing := Missing(granted, Required["spotify_playlist_cover"])
+	if !reflect.DeepEqual(missing, []string{UGCImageUpload}) {
+		t.Errorf("Expected only %s to be missing, got %v", UGCImageUpload, missing)
+	}
+
+	if missing := Missing(granted, Required["spotify_playlist"]); len(missing) != 0 {
+		t.Errorf("Expected no missing scopes, got %v", missing)
+	}
+}
+
+func TestDefaultCoversAllRequired(t *testing.T) {
+	if missing := Missing(Default, All()); len(missing) != 0 {
+		t.Errorf("Expected the default scopes to cover every resource, missing %v", missing)
+	}
+}
+
+func TestRequiredBy(t *testing.T) {
+	names := RequiredBy(UGCImageUpload)
+	if !reflect.DeepEqual(names, []string{"spotify_playlist_cover"}) {
+		t.Errorf("Expected only spotify_playlist_cover to need %s, got %v", UGCImageUpload, names)
+	}
+}
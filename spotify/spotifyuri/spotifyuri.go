@@ -0,0 +1,182 @@
+// Package spotifyuri parses the ways users refer to Spotify objects: bare
+// IDs, spotify: URIs and open.spotify.com links. Local files, which only
+// exist as spotify:local: URIs, are supported as playlist items.
+package spotifyuri
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Spotify object types
+const (
+	Album    = "album"
+	Artist   = "artist"
+	Episode  = "episode"
+	Local    = "local"
+	Playlist = "playlist"
+	Show     = "show"
+	Track    = "track"
+	User     = "user"
+)
+
+// idPattern matches a Spotify base62 ID
+var idPattern = regexp.MustCompile(`^[0-9A-Za-z]{22}$`)
+
+// localPrefix starts the URI of a local file, followed by its URL-encoded
+// artist, album, title and duration
+const localPrefix = "spotify:local:"
+
+// Ref identifies a Spotify object
+type Ref struct {
+	Type string
+	ID   string
+}
+
+// URI returns the canonical spotify: URI of the object
+func (r Ref) URI() string {
+	return "spotify:" + r.Type + ":" + r.ID
+}
+
+// IsID reports whether s is a valid Spotify base62 ID
+func IsID(s string) bool {
+	return idPattern.MatchString(s)
+}
+
+// Parse parses a spotify: URI or an open.spotify.com URL
+func Parse(s string) (Ref, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.HasPrefix(s, "spotify:") {
+		return parseURI(s)
+	}
+	if strings.Contains(s, "spotify.com/") {
+		return parseURL(s)
+	}
+
+	return Ref{}, fmt.Errorf("%q is not a Spotify URI or URL", s)
+}
+
+// ParseID returns the ID of an object of the given type referred to by a bare
+// ID, a spotify: URI or an open.spotify.com URL
+func ParseID(s, objectType string) (string, error) {
+	s = strings.TrimSpace(s)
+
+	if IsID(s) {
+		return s, nil
+	}
+
+	ref, err := Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a Spotify %s ID, URI or URL", s, objectType)
+	}
+	if ref.Type != objectType {
+		return "", fmt.Errorf("%q refers to a %s, expected a %s", s, ref.Type, objectType)
+	}
+
+	return ref.ID, nil
+}
+
+// IsLocal reports whether uri refers to a local file
+func IsLocal(uri string) bool {
+	return strings.HasPrefix(uri, localPrefix)
+}
+
+// NormalizePlaylistItem returns the canonical URI of a playlist item given as
+// a bare track ID, a spotify:track:, spotify:episode: or spotify:local: URI,
+// or an open.spotify.com track or episode link
+func NormalizePlaylistItem(s string) (string, error) {
+	s = strings.TrimSpace(s)
+
+	if IsID(s) {
+		return Ref{Type: Track, ID: s}.URI(), nil
+	}
+
+	ref, err := Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a Spotify track or episode ID, URI or URL", s)
+	}
+
+	switch ref.Type {
+	case Track, Episode, Local:
+		return ref.URI(), nil
+	}
+	return "", fmt.Errorf("%q refers to a %s, playlists can only contain tracks, episodes and local files", s, ref.Type)
+}
+
+// parseURI parses spotify:<type>:<id>, including the legacy
+// spotify:user:<user>:playlist:<id> form
+func parseURI(s string) (Ref, error) {
+	// Local file URIs keep their fields verbatim, they have no ID
+	if IsLocal(s) {
+		if len(s) == len(localPrefix) {
+			return Ref{}, fmt.Errorf("%q is not a valid local file URI", s)
+		}
+		return Ref{Type: Local, ID: strings.TrimPrefix(s, localPrefix)}, nil
+	}
+
+	parts := strings.Split(s, ":")[1:]
+
+	if len(parts) == 4 && parts[0] == User && parts[2] == Playlist {
+		parts = parts[2:]
+	}
+	if len(parts) != 2 {
+		return Ref{}, fmt.Errorf("%q is not a valid Spotify URI", s)
+	}
+
+	return newRef(parts[0], parts[1], s)
+}
+
+// parseURL parses https://open.spotify.com/<type>/<id> links, ignoring query
+// strings, locale prefixes such as /intl-de and the /embed prefix
+func parseURL(s string) (Ref, error) {
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return Ref{}, fmt.Errorf("%q is not a valid Spotify URL: %w", s, err)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host != "open.spotify.com" && host != "play.spotify.com" {
+		return Ref{}, fmt.Errorf("%q is not an open.spotify.com URL", s)
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) > 0 && strings.HasPrefix(segments[0], "intl-") {
+		segments = segments[1:]
+	}
+	if len(segments) > 0 && segments[0] == "embed" {
+		segments = segments[1:]
+	}
+	if len(segments) == 4 && segments[0] == User && segments[2] == Playlist {
+		segments = segments[2:]
+	}
+	if len(segments) != 2 {
+		return Ref{}, fmt.Errorf("%q is not a link to a Spotify object", s)
+	}
+
+	return newRef(segments[0], segments[1], s)
+}
+
+// newRef validates the type and ID parsed from input
+func newRef(objectType, id, input string) (Ref, error) {
+	switch objectType {
+	case Album, Artist, Episode, Playlist, Show, Track:
+		if !IsID(id) {
+			return Ref{}, fmt.Errorf("%q does not contain a valid Spotify ID", input)
+		}
+	case User:
+		if id == "" {
+			return Ref{}, fmt.Errorf("%q does not contain a user ID", input)
+		}
+	default:
+		return Ref{}, fmt.Errorf("%q refers to an unsupported Spotify object type %q", input, objectType)
+	}
+
+	return Ref{Type: objectType, ID: id}, nil
+}
@@ -0,0 +1,89 @@
+package spotifyuri
+
+import "testing"
+
+const playlistID = "37i9dQZF1DXcBWIGoYBM5M"
+
+func TestParseID(t *testing.T) {
+	inputs := []string{
+		playlistID,
+		"spotify:playlist:" + playlistID,
+		"spotify:user:spotify:playlist:" + playlistID,
+		"https://open.spotify.com/playlist/" + playlistID,
+		"https://open.spotify.com/playlist/" + playlistID + "?si=abcdef",
+		"https://open.spotify.com/intl-de/playlist/" + playlistID,
+		"https://open.spotify.com/embed/playlist/" + playlistID,
+		"open.spotify.com/playlist/" + playlistID,
+		"  " + playlistID + "\n",
+	}
+
+	for _, input := range inputs {
+		id, err := ParseID(input, Playlist)
+		if err != nil {
+			t.Errorf("ParseID(%q) returned error: %v", input, err)
+			continue
+		}
+		if id != playlistID {
+			t.Errorf("ParseID(%q) = %q, expected %q", input, id, playlistID)
+		}
+	}
+}
+
+func TestParseIDRejectsInvalidInput(t *testing.T) {
+	inputs := []string{
+		"",
+		"not-an-id",
+		"spotify:track:4iV5W9uYEdYUVa79Axb7Rh",
+		"https://example.com/playlist/" + playlistID,
+		"https://open.spotify.com/playlist/short",
+		"spotify:playlist",
+	}
+
+	for _, input := range inputs {
+		if id, err := ParseID(input, Playlist); err == nil {
+			t.Errorf("ParseID(%q) = %q, expected an error", input, id)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	ref, err := Parse("https://open.spotify.com/track/4iV5W9uYEdYUVa79Axb7Rh?si=x")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ref.Type != Track || ref.ID != "4iV5W9uYEdYUVa79Axb7Rh" {
+		t.Errorf("Unexpected ref %+v", ref)
+	}
+	if ref.URI() != "spotify:track:4iV5W9uYEdYUVa79Axb7Rh" {
+		t.Errorf("Unexpected URI %q", ref.URI())
+	}
+}
+
+func TestNormalizePlaylistItem(t *testing.T) {
+	tests := map[string]string{
+		"4iV5W9uYEdYUVa79Axb7Rh":                                       "spotify:track:4iV5W9uYEdYUVa79Axb7Rh",
+		"spotify:track:4iV5W9uYEdYUVa79Axb7Rh":                         "spotify:track:4iV5W9uYEdYUVa79Axb7Rh",
+		"https://open.spotify.com/track/4iV5W9uYEdYUVa79Axb7Rh?si=abc": "spotify:track:4iV5W9uYEdYUVa79Axb7Rh",
+		"spotify:episode:512ojhOuo1ktJprKbVcKyQ":                       "spotify:episode:512ojhOuo1ktJprKbVcKyQ",
+		"https://open.spotify.com/episode/512ojhOuo1ktJprKbVcKyQ":      "spotify:episode:512ojhOuo1ktJprKbVcKyQ",
+		"spotify:local:Artist:Album:My+Song:215":                       "spotify:local:Artist:Album:My+Song:215",
+		"spotify:local:::Untitled:0":                                   "spotify:local:::Untitled:0",
+	}
+
+	for input, expected := range tests {
+		got, err := NormalizePlaylistItem(input)
+		if err != nil {
+			t.Errorf("NormalizePlaylistItem(%q) returned error: %v", input, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("NormalizePlaylistItem(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+
+	for _, input := range []string{"spotify:album:4aawyAB9vmqN3uQ7FjRGTy", "spotify:local:", "track-1", ""} {
+		if got, err := NormalizePlaylistItem(input); err == nil {
+			t.Errorf("NormalizePlaylistItem(%q) = %q, expected an error", input, got)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/logging"
+)
+
+const (
+	// DefaultMaxRetries is the default number of retries for a single request
+	DefaultMaxRetries = 5
+
+	// DefaultMaxWait is the default upper bound for a single wait between attempts
+	DefaultMaxWait = 60 * time.Second
+
+	// defaultMinBackoff is the first backoff interval when no Retry-After header is present
+	defaultMinBackoff = 500 * time.Millisecond
+)
+
+// RetryTransport is an http.RoundTripper that retries throttled (HTTP 429) and
+// failed (HTTP 5xx) requests. Throttled requests are retried for every method
+// because Spotify rejects them before processing; server errors and network
+// errors are only retried for idempotent methods.
+type RetryTransport struct {
+	// Base is the underlying transport, defaults to http.DefaultTransport
+	Base http.RoundTripper
+
+	// MaxRetries is the retry budget for a single request
+	MaxRetries int
+
+	// MaxWait caps a single wait; a Retry-After above it is not honored and the
+	// throttled response is returned to the caller instead
+	MaxWait time.Duration
+
+	// MinBackoff is the first exponential backoff interval
+	MinBackoff time.Duration
+
+	// sleep waits for the given duration or until the context is done
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewRetryTransport creates a RetryTransport wrapping the given base transport
+func NewRetryTransport(base http.RoundTripper, maxRetries int, maxWait time.Duration) *RetryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if maxWait <= 0 {
+		maxWait = DefaultMaxWait
+	}
+	return &RetryTransport{
+		Base:       base,
+		MaxRetries: maxRetries,
+		MaxWait:    maxWait,
+		MinBackoff: defaultMinBackoff,
+		sleep:      sleepContext,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	logger := logging.DefaultLogger.WithContext(ctx)
+
+	for attempt := 0; ; attempt++ {
+		attemptReq, err := rewindRequest(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.Base.RoundTrip(attemptReq)
+
+		canRetry := attempt < t.MaxRetries && (req.Body == nil || req.GetBody != nil)
+		if !canRetry {
+			return resp, err
+		}
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || !isIdempotent(req.Method) {
+				return resp, err
+			}
+			wait = t.backoff(attempt)
+			logger.Warn("Spotify API request failed, retrying",
+				"method", req.Method,
+				"path", req.URL.Path,
+				"attempt", attempt+1,
+				"wait", wait.String(),
+				"error", err.Error(),
+			)
+
+		case resp.StatusCode == http.StatusTooManyRequests:
+			retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			if !ok {
+				retryAfter = t.backoff(attempt)
+			}
+			if retryAfter > t.MaxWait {
+				logger.Error("Spotify API rate limit exceeds the maximum wait, giving up",
+					"method", req.Method,
+					"path", req.URL.Path,
+					"retry_after", retryAfter.String(),
+					"max_wait", t.MaxWait.String(),
+				)
+				return resp, nil
+			}
+			wait = retryAfter
+			logger.Warn("Spotify API rate limit reached, retrying",
+				"method", req.Method,
+				"path", req.URL.Path,
+				"attempt", attempt+1,
+				"retry_after", wait.String(),
+			)
+
+		case isRetryableStatus(resp.StatusCode) && isIdempotent(req.Method):
+			wait = t.backoff(attempt)
+			logger.Warn("Spotify API server error, retrying",
+				"method", req.Method,
+				"path", req.URL.Path,
+				"status", resp.StatusCode,
+				"attempt", attempt+1,
+				"wait", wait.String(),
+			)
+
+		default:
+			return resp, nil
+		}
+
+		if resp != nil {
+			drainAndClose(resp)
+		}
+
+		if err := t.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff returns a jittered exponential backoff for the given attempt
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	backoff := t.MinBackoff << uint(attempt)
+	if backoff <= 0 || backoff > t.MaxWait {
+		backoff = t.MaxWait
+	}
+
+	// Use "equal jitter": half the interval is fixed, the other half random
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// rewindRequest returns the request to send for the given attempt, resetting
+// the body for retries
+func rewindRequest(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.Body == nil || req.GetBody == nil {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	clone := req.Clone(req.Context())
+	clone.Body = body
+	return clone, nil
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		wait := date.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
+
+// isIdempotent reports whether a request with the given method can be safely replayed
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isRetryableStatus reports whether the status code indicates a transient server error
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// drainAndClose discards the rest of the body so the connection can be reused
+func drainAndClose(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	_ = resp.Body.Close()
+}
+
+// sleepContext waits for the given duration or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
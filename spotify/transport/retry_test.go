@@ -0,0 +1,172 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestTransport returns a RetryTransport that records waits instead of sleeping
+func newTestTransport(maxRetries int, waits *[]time.Duration) *RetryTransport {
+	t := NewRetryTransport(http.DefaultTransport, maxRetries, time.Minute)
+	t.sleep = func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return nil
+	}
+	return t
+}
+
+func TestRetryTransportHonorsRetryAfter(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	client := &http.Client{Transport: newTestTransport(5, &waits)}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+	for _, wait := range waits {
+		if wait != 2*time.Second {
+			t.Errorf("Expected a 2s wait from Retry-After, got %s", wait)
+		}
+	}
+}
+
+func TestRetryTransportRespectsBudget(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	client := &http.Client{Transport: newTestTransport(2, &waits)}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", resp.StatusCode)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls (1 + 2 retries), got %d", calls)
+	}
+}
+
+func TestRetryTransportDoesNotRetryNonIdempotentServerErrors(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	client := &http.Client{Transport: newTestTransport(5, &waits)}
+
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if calls != 1 {
+		t.Errorf("Expected POST not to be retried on 500, got %d calls", calls)
+	}
+}
+
+func TestRetryTransportReplaysBody(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	client := &http.Client{Transport: newTestTransport(5, &waits)}
+
+	req, _ := http.NewRequest(http.MethodPut, server.URL, strings.NewReader("image-data"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if len(bodies) != 2 || bodies[0] != "image-data" || bodies[1] != "image-data" {
+		t.Errorf("Expected the body to be replayed, got %q", bodies)
+	}
+}
+
+func TestRetryTransportGivesUpOnLongRetryAfter(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	client := &http.Client{Transport: newTestTransport(5, &waits)}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+
+	if calls != 1 || len(waits) != 0 {
+		t.Errorf("Expected no retry when Retry-After exceeds the maximum wait, got %d calls", calls)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if wait, ok := parseRetryAfter("5", now); !ok || wait != 5*time.Second {
+		t.Errorf("Expected 5s, got %s (ok=%v)", wait, ok)
+	}
+
+	date := now.Add(10 * time.Second).Format(http.TimeFormat)
+	if wait, ok := parseRetryAfter(date, now); !ok || wait != 10*time.Second {
+		t.Errorf("Expected 10s, got %s (ok=%v)", wait, ok)
+	}
+
+	if _, ok := parseRetryAfter("soon", now); ok {
+		t.Error("Expected an invalid Retry-After to be rejected")
+	}
+}
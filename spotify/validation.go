@@ -0,0 +1,127 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/logging"
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/utils"
+)
+
+// Supported values of the validation_mode provider argument
+const (
+	// validationModeNone makes no API calls at configure time
+	validationModeNone = "none"
+	// validationModeBasic looks up the current user
+	validationModeBasic = "basic"
+	// validationModeDeep probes every endpoint the provider relies on
+	validationModeDeep = "deep"
+)
+
+// healthCheck is an endpoint probed by the deep validation mode
+type healthCheck struct {
+	Name string
+	Path string
+	// UsedBy lists the resources and data sources relying on the endpoint
+	UsedBy []string
+	// RequiresUser is set for endpoints that app-only tokens cannot call
+	RequiresUser bool
+}
+
+// healthChecks are the endpoints probed by the deep validation mode
+var healthChecks = []healthCheck{
+	{Name: "current user", Path: "me", UsedBy: []string{"spotify_user", "spotify_playlist"}, RequiresUser: true},
+	{Name: "top artists", Path: "me/top/artists?limit=1", UsedBy: []string{"spotify_user_preferences", "spotify_tracks"}, RequiresUser: true},
+	{Name: "search", Path: "search?q=pop&type=track&limit=1", UsedBy: []string{"spotify_tracks"}},
+	{Name: "recommendations", Path: "recommendations?seed_genres=pop&limit=1", UsedBy: []string{"spotify_tracks", "spotify_user_preferences"}},
+	{Name: "genre seeds", Path: "recommendations/available-genre-seeds", UsedBy: []string{"spotify_tracks"}},
+	{Name: "new releases", Path: "browse/new-releases?limit=1", UsedBy: []string{"spotify_new_releases"}},
+	{Name: "featured playlists", Path: "browse/featured-playlists?limit=1", UsedBy: []string{"spotify_featured_playlists"}},
+}
+
+// validateCredentials checks the configured credentials according to the validation mode
+func validateCredentials(ctx context.Context, client *ProviderClient, mode string) diag.Diagnostics {
+	logger := logging.DefaultLogger.WithContext(ctx)
+
+	switch mode {
+	case validationModeNone:
+		logger.Debug("Skipping Spotify credentials validation")
+		return nil
+
+	case validationModeDeep:
+		return runHealthChecks(ctx, client)
+
+	default:
+		// App-only tokens have no current user; obtaining the token already proved the credentials
+		if client.AuthMode == authModeClientCredentials {
+			return nil
+		}
+
+		user, err := client.SpotifyClient.CurrentUser(ctx)
+		if err != nil {
+			return diag.FromErr(fmt.Errorf("error authenticating with Spotify: %s", err))
+		}
+		logger.Info("Authenticated with Spotify", "user_id", user.ID)
+		return nil
+	}
+}
+
+// runHealthChecks probes each endpoint and reports unavailable ones as warnings.
+// A failing current user lookup is an error, as nothing user-related can work.
+func runHealthChecks(ctx context.Context, client *ProviderClient) diag.Diagnostics {
+	logger := logging.DefaultLogger.WithContext(ctx)
+	var diags diag.Diagnostics
+
+	for _, check := range healthChecks {
+		if check.RequiresUser && client.AuthMode == authModeClientCredentials {
+			continue
+		}
+
+		status, err := probeEndpoint(ctx, client, check.Path)
+		if err == nil && status == http.StatusOK {
+			logger.Debug("Spotify endpoint available", "endpoint", check.Name)
+			continue
+		}
+
+		detail := fmt.Sprintf("GET /v1/%s", strings.SplitN(check.Path, "?", 2)[0])
+		if err != nil {
+			detail = fmt.Sprintf("%s failed: %s.", detail, err)
+		} else {
+			detail = fmt.Sprintf("%s returned HTTP %d %s.", detail, status, http.StatusText(status))
+		}
+
+		severity := diag.Warning
+		if check.Path == "me" {
+			severity = diag.Error
+		}
+
+		logger.Warn("Spotify endpoint unavailable", "endpoint", check.Name, "status", status)
+		diags = append(diags, diag.Diagnostic{
+			Severity: severity,
+			Summary:  fmt.Sprintf("Spotify %s endpoint is unavailable", check.Name),
+			Detail:   fmt.Sprintf("%s Used by: %s.", detail, strings.Join(check.UsedBy, ", ")),
+		})
+	}
+
+	return diags
+}
+
+// probeEndpoint makes a GET request to the given API path and returns the status code
+func probeEndpoint(ctx context.Context, client *ProviderClient, path string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, client.apiURL(path), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer utils.HandleResponseBodyClose(ctx, resp)
+
+	return resp.StatusCode, nil
+}
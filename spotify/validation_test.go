@@ -0,0 +1,141 @@
+package spotify
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// healthCheckPaths returns the paths probed by the deep validation mode,
+// leaving out the ones requiring a user unless withUser is set
+func healthCheckPaths(withUser bool) []string {
+	var paths []string
+	for _, check := range healthChecks {
+		if check.RequiresUser && !withUser {
+			continue
+		}
+		paths = append(paths, strings.SplitN(check.Path, "?", 2)[0])
+	}
+	return paths
+}
+
+func TestValidateCredentialsModes(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		// status overrides the status of API paths
+		status map[string]int
+		// paths are the API paths expected to be requested
+		paths    []string
+		err      bool
+		warnings int
+	}{
+		{
+			name:   "none",
+			config: map[string]interface{}{"validation_mode": validationModeNone},
+		},
+		{
+			name:   "skip_credentials_validation",
+			config: map[string]interface{}{"skip_credentials_validation": true},
+		},
+		{
+			name:  "basic",
+			paths: []string{"me"},
+		},
+		{
+			name:   "basic with a rejected token",
+			status: map[string]int{"me": 403},
+			paths:  []string{"me"},
+			err:    true,
+		},
+		{
+			name:   "basic with client_credentials",
+			config: map[string]interface{}{"auth_mode": authModeClientCredentials},
+		},
+		{
+			name:   "deep",
+			config: map[string]interface{}{"validation_mode": validationModeDeep},
+			paths:  healthCheckPaths(true),
+		},
+		{
+			name:     "deep with an unavailable endpoint",
+			config:   map[string]interface{}{"validation_mode": validationModeDeep},
+			status:   map[string]int{"browse/new-releases": 404},
+			paths:    healthCheckPaths(true),
+			warnings: 1,
+		},
+		{
+			name:   "deep with a rejected token",
+			config: map[string]interface{}{"validation_mode": validationModeDeep},
+			status: map[string]int{"me": 403},
+			paths:  healthCheckPaths(true),
+			err:    true,
+		},
+		{
+			name:   "deep with client_credentials",
+			config: map[string]interface{}{"validation_mode": validationModeDeep, "auth_mode": authModeClientCredentials},
+			paths:  healthCheckPaths(false),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSpotifyServer(t)
+			server.status = tt.status
+
+			config := map[string]interface{}{
+				"client_id":         "client",
+				"client_secret":     "secret",
+				"refresh_token":     "refresh",
+				"api_base_url":      server.URL,
+				"accounts_base_url": server.URL,
+				"max_retries":       0,
+			}
+			for k, v := range tt.config {
+				config[k] = v
+			}
+
+			_, diags := configureProvider(t, config)
+			if diags.HasError() != tt.err {
+				t.Errorf("Expected error=%v, got %v", tt.err, diags)
+			}
+
+			warnings := 0
+			for _, d := range diags {
+				if d.Severity == diag.Warning {
+					warnings++
+				}
+			}
+			if warnings != tt.warnings {
+				t.Errorf("Expected %d warnings, got %v", tt.warnings, diags)
+			}
+
+			_, paths := server.requests()
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("Expected requests to %v, got %v", tt.paths, paths)
+			}
+		})
+	}
+}
+
+func TestRunHealthChecksNamesAffectedDataSources(t *testing.T) {
+	server := newFakeSpotifyServer(t)
+	server.status = map[string]int{"browse/featured-playlists": 404}
+
+	_, diags := configureProvider(t, map[string]interface{}{
+		"client_id":         "client",
+		"client_secret":     "secret",
+		"refresh_token":     "refresh",
+		"api_base_url":      server.URL,
+		"accounts_base_url": server.URL,
+		"validation_mode":   validationModeDeep,
+	})
+	if len(diags) != 1 {
+		t.Fatalf("Expected a single warning, got %v", diags)
+	}
+	if !strings.Contains(diags[0].Detail, "HTTP 404") || !strings.Contains(diags[0].Detail, "spotify_featured_playlists") {
+		t.Errorf("Expected the warning to name the status and the affected data source, got %q", diags[0].Detail)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// callbackResult carries the outcome of the OAuth redirect to the local server
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// newState generates a random state value used to protect the flow against CSRF
+func newState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating state: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// isLoopbackRedirect reports whether the redirect URI points at this machine,
+// so the authorization code can be captured by a local server
+func isLoopbackRedirect(redirect string) bool {
+	u, err := url.Parse(redirect)
+	if err != nil || u.Scheme != "http" {
+		return false
+	}
+
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// checkCallback validates the query of an OAuth redirect and returns the authorization code
+func checkCallback(query url.Values, expectedState string) (string, error) {
+	if errCode := query.Get("error"); errCode != "" {
+		return "", fmt.Errorf("authorization denied: %s", errCode)
+	}
+
+	state := query.Get("state")
+	if subtle.ConstantTimeCompare([]byte(state), []byte(expectedState)) != 1 {
+		return "", errors.New("state mismatch, the callback did not originate from this login")
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		return "", errors.New("callback did not include an authorization code")
+	}
+
+	return code, nil
+}
+
+// waitForCallback starts a loopback HTTP server on the redirect URI and waits
+// for Spotify to redirect the browser back with an authorization code
+func waitForCallback(ctx context.Context, redirect string, expectedState string, timeout time.Duration) (string, error) {
+	u, err := url.Parse(redirect)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect URI: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", u.Host)
+	if err != nil {
+		return "", fmt.Errorf("error listening on %s: %w", u.Host, err)
+	}
+
+	callbackPath := u.Path
+	if callbackPath == "" {
+		callbackPath = "/"
+	}
+
+	results := make(chan callbackResult, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc(callbackPath, func(w http.ResponseWriter, r *http.Request) {
+		code, err := checkCallback(r.URL.Query(), expectedState)
+		if err != nil {
+			http.Error(w, "Spotify authorization failed: "+err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "Spotify authorization complete. You can close this window and return to the terminal.")
+		}
+
+		// Only the first callback counts
+		select {
+		case results <- callbackResult{code: code, err: err}:
+		default:
+		}
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			select {
+			case results <- callbackResult{err: fmt.Errorf("callback server failed: %w", err)}:
+			default:
+			}
+		}
+	}()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-results:
+		return result.code, result.err
+	case <-timer.C:
+		return "", fmt.Errorf("timed out after %s waiting for the authorization callback", timeout)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// readPastedCode parses what the user pasted in the manual flow: either the
+// bare code, or the full redirect URL, whose state is then verified too
+func readPastedCode(input string, expectedState string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", errors.New("authorization code cannot be empty")
+	}
+
+	if strings.Contains(input, "code=") {
+		rawQuery := input
+		if u, err := url.Parse(input); err == nil && u.RawQuery != "" {
+			rawQuery = u.RawQuery
+		}
+		query, err := url.ParseQuery(strings.TrimPrefix(rawQuery, "?"))
+		if err != nil {
+			return "", fmt.Errorf("error parsing redirect URL: %w", err)
+		}
+		return checkCallback(query, expectedState)
+	}
+
+	return input, nil
+}
+
+// openBrowser opens the given URL in the default browser
+func openBrowser(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestIsLoopbackRedirect(t *testing.T) {
+	cases := map[string]bool{
+		"http://127.0.0.1:8080/callback":             true,
+		"http://localhost:8888/callback":             true,
+		"http://[::1]:8080/callback":                 true,
+		"https://127.0.0.1:8080/callback":            false,
+		"https://glitch.com/~spotify-oauth-redirect": false,
+	}
+
+	for redirect, expected := range cases {
+		if isLoopbackRedirect(redirect) != expected {
+			t.Errorf("Expected isLoopbackRedirect(%q) to be %v", redirect, expected)
+		}
+	}
+}
+
+func TestReadPastedCode(t *testing.T) {
+	if code, err := readPastedCode("  abc123\n", "state"); err != nil || code != "abc123" {
+		t.Errorf("Expected bare code 'abc123', got '%s' (%v)", code, err)
+	}
+
+	code, err := readPastedCode("https://example.com/callback?code=abc123&state=state", "state")
+	if err != nil || code != "abc123" {
+		t.Errorf("Expected code from URL 'abc123', got '%s' (%v)", code, err)
+	}
+
+	if _, err := readPastedCode("https://example.com/callback?code=abc123&state=forged", "state"); err == nil {
+		t.Error("Expected a state mismatch to be rejected")
+	}
+}
+
+func TestWaitForCallback(t *testing.T) {
+	// Find a free port for the callback server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	redirect := "http://" + addr + "/callback"
+	done := make(chan struct{})
+	var code string
+	var waitErr error
+	go func() {
+		code, waitErr = waitForCallback(context.Background(), redirect, "expected-state", 5*time.Second)
+		close(done)
+	}()
+
+	// Retry until the server is up
+	query := url.Values{"code": {"abc123"}, "state": {"expected-state"}}
+	for i := 0; i < 50; i++ {
+		resp, err := http.Get(redirect + "?" + query.Encode())
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	<-done
+	if waitErr != nil || code != "abc123" {
+		t.Errorf("Expected code 'abc123', got '%s' (%v)", code, waitErr)
+	}
+}
+
+func TestWaitForCallbackTimesOut(t *testing.T) {
+	_, err := waitForCallback(context.Background(), "http://127.0.0.1:0/callback", "state", 50*time.Millisecond)
+	if err == nil {
+		t.Error("Expected a timeout error")
+	}
+}
@@ -0,0 +1,588 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/credentials"
+	spotifyscopes "github.com/ashrafxbilal/terraform-provider-spotify/spotify/scopes"
+)
+
+// Subcommand names
+const (
+	commandLogin   = "login"
+	commandRefresh = "refresh"
+	commandInspect = "inspect"
+	commandDoctor  = "doctor"
+)
+
+// command is a subcommand of the auth proxy
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	// Assigned in init because printUsage refers back to the table
+	commands = map[string]command{
+		commandLogin:   {"authorize the app and obtain a refresh token (default)", runLogin},
+		commandRefresh: {"exchange a refresh token and print the new access token", runRefresh},
+		commandInspect: {"show the user, product and granted scopes of a refresh token", runInspect},
+		commandDoctor:  {"check the environment, redirect URI, clock skew and refresh token", runDoctor},
+		"help":         {"show this help", func([]string) error { printUsage(); return nil }},
+	}
+}
+
+// printUsage lists the available subcommands
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: spotify-auth [command] [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun 'spotify-auth <command> -h' for the flags of a command.")
+}
+
+// validateFormat checks the value of a -format flag
+func validateFormat(format string) error {
+	if format != formatText && format != formatJSON {
+		return fmt.Errorf("invalid -format %q, expected %q or %q", format, formatText, formatJSON)
+	}
+	return nil
+}
+
+// validateClientEnvironment checks the environment variables needed to use a refresh token
+func validateClientEnvironment() error {
+	missing := []string{}
+
+	if clientID == "" {
+		missing = append(missing, "SPOTIFY_CLIENT_ID")
+	}
+	if clientSecret == "" {
+		missing = append(missing, "SPOTIFY_CLIENT_SECRET")
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required environment variables: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// tokenFlags registers the flags selecting the refresh token to use
+func tokenFlags(flags *flag.FlagSet) (refreshToken, tokenFile *string) {
+	refreshToken = flags.String("refresh-token", os.Getenv("SPOTIFY_REFRESH_TOKEN"), "refresh token to use (defaults to SPOTIFY_REFRESH_TOKEN)")
+	tokenFile = flags.String("token-file", os.Getenv("SPOTIFY_TOKEN_FILE"), "read the refresh token from this token file and store the refreshed token in it")
+	return refreshToken, tokenFile
+}
+
+// refreshCredentials exchanges the refresh token, read from the token file if
+// one is given, for a new access token. Rotated refresh tokens are written back
+// to the token file. The returned response always carries the refresh token to
+// use from now on, rotated reports whether Spotify issued a new one.
+func refreshCredentials(refreshToken, tokenFile string) (tokens *SpotifyTokenResponse, rotated bool, err error) {
+	if tokenFile == "" {
+		if refreshToken == "" {
+			return nil, false, fmt.Errorf("no refresh token, set SPOTIFY_REFRESH_TOKEN or use -refresh-token or -token-file")
+		}
+		return refreshWith(refreshToken)
+	}
+
+	store := credentials.NewFileStore(tokenFile)
+	unlock, err := store.Lock()
+	if err != nil {
+		return nil, false, err
+	}
+	defer unlock()
+
+	current, err := store.Load()
+	if err != nil {
+		return nil, false, err
+	}
+	if current.RefreshToken == "" {
+		return nil, false, fmt.Errorf("token file %s has no refresh_token", tokenFile)
+	}
+
+	tokens, rotated, err = refreshWith(current.RefreshToken)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := store.Save(oauth2Token(tokens, time.Now())); err != nil {
+		return nil, false, err
+	}
+
+	return tokens, rotated, nil
+}
+
+// refreshWith refreshes the given refresh token, keeping it if Spotify did not rotate it
+func refreshWith(refreshToken string) (*SpotifyTokenResponse, bool, error) {
+	tokens, err := refreshAccessToken(refreshToken)
+	if err != nil {
+		if errors.Is(err, errInvalidGrant) {
+			return nil, false, fmt.Errorf("%w (the refresh token is invalid or has been revoked, run login again)", err)
+		}
+		return nil, false, err
+	}
+
+	rotated := tokens.RefreshToken != "" && tokens.RefreshToken != refreshToken
+	if tokens.RefreshToken == "" {
+		tokens.RefreshToken = refreshToken
+	}
+
+	return tokens, rotated, nil
+}
+
+// refreshResult is the machine-readable result of the refresh command
+type refreshResult struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	Scope        string    `json:"scope"`
+	ExpiresIn    int       `json:"expires_in"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"`
+	Rotated      bool      `json:"rotated"`
+}
+
+// runRefresh exchanges a refresh token and prints the new access token and expiry
+func runRefresh(args []string) error {
+	flags := flag.NewFlagSet(commandRefresh, flag.ExitOnError)
+	refreshToken, tokenFile := tokenFlags(flags)
+	format := flags.String("format", formatText, "stdout format: text or json")
+	_ = flags.Parse(args)
+
+	if err := validateFormat(*format); err != nil {
+		return err
+	}
+	if err := validateClientEnvironment(); err != nil {
+		return err
+	}
+
+	tokens, rotated, err := refreshCredentials(*refreshToken, *tokenFile)
+	if err != nil {
+		return err
+	}
+
+	result := refreshResult{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    tokens.TokenType,
+		Scope:        tokens.Scope,
+		ExpiresIn:    tokens.ExpiresIn,
+		ExpiresAt:    time.Now().Add(time.Duration(tokens.ExpiresIn) * time.Second).UTC().Truncate(time.Second),
+		RefreshToken: tokens.RefreshToken,
+		Rotated:      rotated,
+	}
+
+	if *format == formatJSON {
+		return writeJSON(os.Stdout, result)
+	}
+
+	fmt.Println("Access Token:", result.AccessToken)
+	fmt.Printf("Expires At: %s (in %s)\n", result.ExpiresAt.Format(time.RFC3339), time.Duration(result.ExpiresIn)*time.Second)
+	fmt.Println("Scope:", result.Scope)
+	if rotated {
+		fmt.Println("Refresh Token:", result.RefreshToken)
+		if *tokenFile != "" {
+			fmt.Fprintf(os.Stderr, "Spotify rotated the refresh token, the new one was stored in %s\n", *tokenFile)
+		} else {
+			fmt.Fprintln(os.Stderr, "Spotify rotated the refresh token, update your configuration with the new one")
+		}
+	}
+
+	return nil
+}
+
+// userProfile is the subset of the /v1/me response shown by inspect
+type userProfile struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	Email       string `json:"email,omitempty"`
+	Country     string `json:"country,omitempty"`
+	Product     string `json:"product,omitempty"`
+}
+
+// inspectResult is the machine-readable result of the inspect command
+type inspectResult struct {
+	User          userProfile         `json:"user"`
+	GrantedScopes []string            `json:"granted_scopes"`
+	MissingScopes map[string][]string `json:"missing_scopes"`
+
+	// RefreshToken is only set when Spotify rotated the refresh token and
+	// no token file stores the new one
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// runInspect reports the user, product and granted scopes of a refresh token
+// versus the scopes the provider needs
+func runInspect(args []string) error {
+	flags := flag.NewFlagSet(commandInspect, flag.ExitOnError)
+	refreshToken, tokenFile := tokenFlags(flags)
+	format := flags.String("format", formatText, "stdout format: text or json")
+	_ = flags.Parse(args)
+
+	if err := validateFormat(*format); err != nil {
+		return err
+	}
+	if err := validateClientEnvironment(); err != nil {
+		return err
+	}
+
+	tokens, rotated, err := refreshCredentials(*refreshToken, *tokenFile)
+	if err != nil {
+		return err
+	}
+	user, err := getCurrentUser(tokens.AccessToken)
+	if err != nil {
+		return err
+	}
+
+	granted := spotifyscopes.Parse(tokens.Scope)
+	result := inspectResult{
+		User:          *user,
+		GrantedScopes: granted,
+		MissingScopes: missingProviderScopes(granted),
+	}
+
+	// The previous refresh token may already be revoked, so a rotated one
+	// must not be lost
+	if rotated {
+		if *tokenFile != "" {
+			fmt.Fprintf(os.Stderr, "Spotify rotated the refresh token, the new one was stored in %s\n", *tokenFile)
+		} else {
+			result.RefreshToken = tokens.RefreshToken
+			fmt.Fprintln(os.Stderr, "Spotify rotated the refresh token, update your configuration with the new one")
+		}
+	}
+
+	if *format == formatJSON {
+		return writeJSON(os.Stdout, result)
+	}
+
+	fmt.Printf("User:    %s (%s)\n", user.DisplayName, user.ID)
+	if user.Email != "" {
+		fmt.Println("Email:  ", user.Email)
+	}
+	if user.Country != "" {
+		fmt.Println("Country:", user.Country)
+	}
+	if user.Product != "" {
+		fmt.Println("Product:", user.Product)
+	}
+	if result.RefreshToken != "" {
+		fmt.Println("Refresh Token:", result.RefreshToken)
+	}
+
+	fmt.Println("\nGranted scopes:")
+	for _, scope := range granted {
+		fmt.Println("  -", scope)
+	}
+
+	fmt.Println("\nProvider scopes:")
+	types := make([]string, 0, len(spotifyscopes.Required))
+	for name := range spotifyscopes.Required {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	for _, name := range types {
+		if missing, ok := result.MissingScopes[name]; ok {
+			fmt.Printf("  MISSING  %s: %s\n", name, strings.Join(missing, ", "))
+		} else {
+			fmt.Printf("  OK       %s\n", name)
+		}
+	}
+
+	if len(result.MissingScopes) > 0 {
+		fmt.Println("\nRun login again with SPOTIFY_SCOPES including the missing scopes to use these resources.")
+	}
+
+	return nil
+}
+
+// missingProviderScopes returns the scopes each provider resource and data
+// source needs that are not granted, omitting types with every scope granted
+func missingProviderScopes(granted []string) map[string][]string {
+	missing := map[string][]string{}
+	for name, required := range spotifyscopes.Required {
+		if m := spotifyscopes.Missing(granted, required); len(m) > 0 {
+			missing[name] = m
+		}
+	}
+	return missing
+}
+
+// getCurrentUser fetches the profile of the user the access token belongs to
+func getCurrentUser(accessToken string) (*userProfile, error) {
+	req, err := http.NewRequest("GET", apiBaseURL+"/v1/me", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET /v1/me failed with status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	var user userProfile
+	if err := json.Unmarshal(body, &user); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &user, nil
+}
+
+// Doctor check statuses
+const (
+	statusOK   = "ok"
+	statusWarn = "warn"
+	statusFail = "fail"
+)
+
+// Clock skew thresholds. Spotify tokens are valid for an hour, so a few
+// minutes of skew already makes expiry handling unreliable.
+const (
+	clockSkewWarn = 30 * time.Second
+	clockSkewFail = 5 * time.Minute
+)
+
+// checkResult is the outcome of a single doctor check
+type checkResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// runDoctor checks the environment variables, the redirect URI, the clock skew
+// against Spotify and, when one is available, the refresh token
+func runDoctor(args []string) error {
+	flags := flag.NewFlagSet(commandDoctor, flag.ExitOnError)
+	refreshToken, tokenFile := tokenFlags(flags)
+	format := flags.String("format", formatText, "stdout format: text or json")
+	_ = flags.Parse(args)
+
+	if err := validateFormat(*format); err != nil {
+		return err
+	}
+
+	var results []checkResult
+	results = append(results, checkEnvironment()...)
+	results = append(results, checkRedirectURI(redirectURI))
+	results = append(results, checkClockSkew())
+	results = append(results, checkRefreshToken(*refreshToken, *tokenFile))
+
+	if *format == formatJSON {
+		if err := writeJSON(os.Stdout, results); err != nil {
+			return err
+		}
+	} else {
+		for _, result := range results {
+			fmt.Printf("[%-4s] %-22s %s\n", strings.ToUpper(result.Status), result.Name, result.Detail)
+		}
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Status == statusFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+
+	return nil
+}
+
+// checkEnvironment checks the environment variables used by the auth proxy and the provider
+func checkEnvironment() []checkResult {
+	var results []checkResult
+
+	for _, name := range []string{"SPOTIFY_CLIENT_ID", "SPOTIFY_CLIENT_SECRET", "SPOTIFY_REDIRECT_URI"} {
+		if os.Getenv(name) == "" {
+			results = append(results, checkResult{name, statusFail, "not set"})
+		} else {
+			results = append(results, checkResult{name, statusOK, "set"})
+		}
+	}
+
+	requested := os.Getenv("SPOTIFY_SCOPES")
+	switch missing := spotifyscopes.Missing(spotifyscopes.Parse(requested), spotifyscopes.All()); {
+	case requested == "":
+		results = append(results, checkResult{"SPOTIFY_SCOPES", statusOK, "not set, login requests the default scopes"})
+	case len(missing) > 0:
+		results = append(results, checkResult{"SPOTIFY_SCOPES", statusWarn, "does not request " + strings.Join(missing, ", ")})
+	default:
+		results = append(results, checkResult{"SPOTIFY_SCOPES", statusOK, "requests every scope the provider uses"})
+	}
+
+	return results
+}
+
+// checkRedirectURI checks that the redirect URI is one Spotify accepts
+func checkRedirectURI(redirect string) checkResult {
+	const name = "redirect URI"
+
+	if redirect == "" {
+		return checkResult{name, statusFail, "SPOTIFY_REDIRECT_URI is not set"}
+	}
+
+	u, err := url.Parse(redirect)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return checkResult{name, statusFail, fmt.Sprintf("%q is not an absolute URL", redirect)}
+	}
+	if u.Fragment != "" {
+		return checkResult{name, statusFail, "must not contain a fragment"}
+	}
+
+	switch u.Scheme {
+	case "https":
+		return checkResult{name, statusOK, "HTTPS, login asks for the code to be pasted"}
+
+	case "http":
+		host := u.Hostname()
+		if host == "localhost" {
+			return checkResult{name, statusWarn, "Spotify no longer accepts localhost, register http://127.0.0.1" + portSuffix(u) + u.Path + " instead"}
+		}
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+			return checkResult{name, statusOK, "loopback, login captures the code automatically"}
+		}
+		return checkResult{name, statusFail, "Spotify only allows plain HTTP for loopback addresses such as 127.0.0.1"}
+	}
+
+	return checkResult{name, statusFail, fmt.Sprintf("unsupported scheme %q", u.Scheme)}
+}
+
+// portSuffix returns ":port" for URLs with an explicit port
+func portSuffix(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return ":" + port
+	}
+	return ""
+}
+
+// checkClockSkew compares the local clock with the Date header of the Spotify Accounts service
+func checkClockSkew() checkResult {
+	const name = "clock skew"
+
+	sent := time.Now()
+	resp, err := http.Head(accountsBaseURL)
+	received := time.Now()
+	if err != nil {
+		return checkResult{name, statusWarn, fmt.Sprintf("could not reach %s: %v", accountsBaseURL, err)}
+	}
+	resp.Body.Close()
+
+	skew, err := clockSkew(resp.Header.Get("Date"), sent, received)
+	if err != nil {
+		return checkResult{name, statusWarn, err.Error()}
+	}
+
+	detail := fmt.Sprintf("local clock is %s off", skew)
+	switch abs := skew.Abs(); {
+	case abs >= clockSkewFail:
+		return checkResult{name, statusFail, detail + ", token expiry will be misjudged"}
+	case abs >= clockSkewWarn:
+		return checkResult{name, statusWarn, detail}
+	}
+	return checkResult{name, statusOK, detail}
+}
+
+// clockSkew estimates how far the local clock is ahead of the server, using
+// the midpoint of the request as the local time the Date header was generated
+func clockSkew(date string, sent, received time.Time) (time.Duration, error) {
+	if date == "" {
+		return 0, fmt.Errorf("response has no Date header")
+	}
+
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Date header %q: %w", date, err)
+	}
+
+	local := sent.Add(received.Sub(sent) / 2)
+	skew := local.Sub(serverTime)
+
+	// The Date header has a resolution of one second
+	if skew.Abs() < time.Second {
+		return 0, nil
+	}
+	return skew.Round(time.Second), nil
+}
+
+// checkRefreshToken checks that the refresh token has not been revoked and
+// grants the scopes the provider needs. Checking spends a refresh, so a
+// rotated refresh token is stored in the token file or reported in the result.
+func checkRefreshToken(refreshToken, tokenFile string) checkResult {
+	const name = "refresh token"
+
+	if refreshToken == "" && tokenFile == "" {
+		return checkResult{name, statusWarn, "SPOTIFY_REFRESH_TOKEN is not set, skipped"}
+	}
+	if err := validateClientEnvironment(); err != nil {
+		return checkResult{name, statusWarn, "skipped, " + err.Error()}
+	}
+
+	tokens, rotated, err := refreshCredentials(refreshToken, tokenFile)
+	if err != nil {
+		return checkResult{name, statusFail, err.Error()}
+	}
+
+	var notes []string
+	switch {
+	case rotated && tokenFile != "":
+		notes = append(notes, "Spotify rotated it, the new one was stored in "+tokenFile)
+	case rotated:
+		notes = append(notes, "Spotify rotated it, update your configuration with the new one: "+tokens.RefreshToken)
+	}
+
+	missing := missingProviderScopes(spotifyscopes.Parse(tokens.Scope))
+	if len(missing) > 0 {
+		types := make([]string, 0, len(missing))
+		for name := range missing {
+			types = append(types, name)
+		}
+		sort.Strings(types)
+		notes = append(notes, "missing scopes for "+strings.Join(types, ", "))
+	}
+
+	if len(notes) > 0 {
+		return checkResult{name, statusWarn, "valid, " + strings.Join(notes, "; ")}
+	}
+	return checkResult{name, statusOK, "valid, grants every scope the provider uses"}
+}
+
+// writeJSON writes v as indented JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/credentials"
+	"golang.org/x/oauth2"
+)
+
+// newTestAccounts starts a fake Spotify Accounts service answering refresh
+// token requests with handler
+func newTestAccounts(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	previousAccounts, previousAPI := accountsBaseURL, apiBaseURL
+	accountsBaseURL, apiBaseURL = server.URL, server.URL
+	clientID, clientSecret = "test-client-id", "test-client-secret"
+	t.Cleanup(func() {
+		accountsBaseURL, apiBaseURL = previousAccounts, previousAPI
+		clientID, clientSecret = "", ""
+	})
+}
+
+func TestRefreshCredentialsRotatesTokenFile(t *testing.T) {
+	newTestAccounts(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("refresh_token") != "old-refresh-token" {
+			t.Errorf("Unexpected refresh request: %v", r.Form)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"new-access-token","token_type":"Bearer","scope":"user-read-private","expires_in":3600,"refresh_token":"new-refresh-token"}`))
+	})
+
+	path := filepath.Join(t.TempDir(), "token.json")
+	store := credentials.NewFileStore(path)
+	if err := store.Save(&oauth2.Token{RefreshToken: "old-refresh-token"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tokens, rotated, err := refreshCredentials("", path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !rotated || tokens.AccessToken != "new-access-token" {
+		t.Errorf("Expected a rotated token, got %+v (rotated=%v)", tokens, rotated)
+	}
+
+	stored, err := store.Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stored.RefreshToken != "new-refresh-token" || stored.AccessToken != "new-access-token" {
+		t.Errorf("Expected the rotated token to be stored, got %+v", stored)
+	}
+}
+
+func TestRefreshCredentialsKeepsRefreshToken(t *testing.T) {
+	newTestAccounts(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"new-access-token","token_type":"Bearer","expires_in":3600}`))
+	})
+
+	tokens, rotated, err := refreshCredentials("refresh-token", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rotated || tokens.RefreshToken != "refresh-token" {
+		t.Errorf("Expected the refresh token to be kept, got %+v (rotated=%v)", tokens, rotated)
+	}
+}
+
+func TestRefreshCredentialsRevoked(t *testing.T) {
+	newTestAccounts(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant","error_description":"Refresh token revoked"}`))
+	})
+
+	_, _, err := refreshCredentials("revoked-token", "")
+	if !errors.Is(err, errInvalidGrant) {
+		t.Fatalf("Expected an invalid_grant error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "revoked") {
+		t.Errorf("Expected the error to mention revocation, got %v", err)
+	}
+}
+
+func TestCheckRefreshTokenReportsMissingScopes(t *testing.T) {
+	newTestAccounts(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"access-token","scope":"user-read-private","expires_in":3600}`))
+	})
+
+	result := checkRefreshToken("refresh-token", "")
+	if result.Status != statusWarn || !strings.Contains(result.Detail, "spotify_playlist") {
+		t.Errorf("Expected a warning about missing playlist scopes, got %+v", result)
+	}
+}
+
+func TestCheckRefreshTokenReportsRotatedToken(t *testing.T) {
+	newTestAccounts(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"access-token","scope":"user-read-private","expires_in":3600,"refresh_token":"new-refresh-token"}`))
+	})
+
+	result := checkRefreshToken("old-refresh-token", "")
+	if !strings.Contains(result.Detail, "new-refresh-token") {
+		t.Errorf("Expected the rotated refresh token to be reported, got %+v", result)
+	}
+
+	path := filepath.Join(t.TempDir(), "token.json")
+	store := credentials.NewFileStore(path)
+	if err := store.Save(&oauth2.Token{RefreshToken: "old-refresh-token"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	result = checkRefreshToken("", path)
+	if strings.Contains(result.Detail, "new-refresh-token") || !strings.Contains(result.Detail, path) {
+		t.Errorf("Expected the rotated refresh token to be stored rather than reported, got %+v", result)
+	}
+	if stored, err := store.Load(); err != nil || stored.RefreshToken != "new-refresh-token" {
+		t.Errorf("Expected the rotated refresh token to be stored, got %+v (%v)", stored, err)
+	}
+}
+
+func TestGetCurrentUser(t *testing.T) {
+	newTestAccounts(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/me" || r.Header.Get("Authorization") != "Bearer access-token" {
+			t.Errorf("Unexpected request %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		w.Write([]byte(`{"id":"user-1","display_name":"Test User","product":"premium"}`))
+	})
+
+	user, err := getCurrentUser("access-token")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if user.ID != "user-1" || user.Product != "premium" {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+}
+
+func TestMissingProviderScopes(t *testing.T) {
+	missing := missingProviderScopes([]string{"playlist-modify-public", "playlist-modify-private", "user-read-private"})
+
+	if _, ok := missing["spotify_playlist"]; ok {
+		t.Error("Expected spotify_playlist to have every scope")
+	}
+	if got := missing["spotify_playlist_cover"]; len(got) != 1 || got[0] != "ugc-image-upload" {
+		t.Errorf("Expected spotify_playlist_cover to miss ugc-image-upload, got %v", got)
+	}
+}
+
+func TestCheckRedirectURI(t *testing.T) {
+	tests := []struct {
+		redirect string
+		status   string
+	}{
+		{"http://127.0.0.1:8080/callback", statusOK},
+		{"http://[::1]:8080/callback", statusOK},
+		{"https://example.com/callback", statusOK},
+		{"http://localhost:8080/callback", statusWarn},
+		{"http://example.com/callback", statusFail},
+		{"https://example.com/callback#fragment", statusFail},
+		{"/callback", statusFail},
+		{"", statusFail},
+	}
+
+	for _, tt := range tests {
+		if result := checkRedirectURI(tt.redirect); result.Status != tt.status {
+			t.Errorf("checkRedirectURI(%q) = %s (%s), expected %s", tt.redirect, result.Status, result.Detail, tt.status)
+		}
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	server := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	date := server.Format(http.TimeFormat)
+
+	skew, err := clockSkew(date, server.Add(2*time.Minute), server.Add(2*time.Minute+200*time.Millisecond))
+	if err != nil || skew != 2*time.Minute {
+		t.Errorf("Expected a 2m skew, got %s (%v)", skew, err)
+	}
+
+	skew, err = clockSkew(date, server.Add(100*time.Millisecond), server.Add(300*time.Millisecond))
+	if err != nil || skew != 0 {
+		t.Errorf("Expected no skew below the Date resolution, got %s (%v)", skew, err)
+	}
+
+	if _, err := clockSkew("", server, server); err == nil {
+		t.Error("Expected an error for a missing Date header")
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/credentials"
+	"golang.org/x/oauth2"
+)
+
+// Supported values of the -format flag
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+// outputOptions selects where the obtained credentials are written
+type outputOptions struct {
+	Format        string
+	TFVarsPath    string
+	EnvFilePath   string
+	TokenFilePath string
+}
+
+// credentialsOutput is the machine-readable result of a login. The client
+// secret is deliberately left out, it is already in the caller's environment.
+type credentialsOutput struct {
+	ClientID     string `json:"client_id"`
+	RedirectURI  string `json:"redirect_uri"`
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	Scope        string `json:"scope"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// writeOutputs prints the result to stdout in the requested format and
+// writes each requested file
+func writeOutputs(stdout io.Writer, opts outputOptions, tokens *SpotifyTokenResponse) error {
+	if opts.TFVarsPath != "" {
+		if err := writePrivateFile(opts.TFVarsPath, renderTFVars(opts.TFVarsPath, tokens)); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Wrote Terraform variables to %s\n", opts.TFVarsPath)
+	}
+
+	if opts.EnvFilePath != "" {
+		if err := writePrivateFile(opts.EnvFilePath, renderEnvFile(tokens)); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Wrote environment file to %s\n", opts.EnvFilePath)
+	}
+
+	if opts.TokenFilePath != "" {
+		if err := writeTokenFile(opts.TokenFilePath, tokens); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Wrote provider token file to %s\n", opts.TokenFilePath)
+	}
+
+	switch opts.Format {
+	case formatJSON:
+		return writeJSON(stdout, credentialsOutput{
+			ClientID:     clientID,
+			RedirectURI:  redirectURI,
+			AccessToken:  tokens.AccessToken,
+			TokenType:    tokens.TokenType,
+			Scope:        tokens.Scope,
+			ExpiresIn:    tokens.ExpiresIn,
+			RefreshToken: tokens.RefreshToken,
+		})
+
+	default:
+		fmt.Fprintln(stdout, "\nAccess Token:", tokens.AccessToken)
+		fmt.Fprintln(stdout, "Refresh Token:", tokens.RefreshToken)
+		fmt.Fprintln(stdout, "\nTo use these tokens with Terraform, set the following environment variables:")
+		fmt.Fprintln(stdout, "export TF_VAR_spotify_client_id=\""+clientID+"\"")
+		fmt.Fprintln(stdout, "export TF_VAR_spotify_client_secret=\"$SPOTIFY_CLIENT_SECRET\"")
+		fmt.Fprintln(stdout, "export TF_VAR_spotify_redirect_uri=\""+redirectURI+"\"")
+		fmt.Fprintln(stdout, "export TF_VAR_spotify_refresh_token=\""+tokens.RefreshToken+"\"")
+		return nil
+	}
+}
+
+// terraformVariables returns the Terraform variables used by the examples
+func terraformVariables(tokens *SpotifyTokenResponse) map[string]string {
+	return map[string]string{
+		"spotify_client_id":     clientID,
+		"spotify_client_secret": clientSecret,
+		"spotify_redirect_uri":  redirectURI,
+		"spotify_refresh_token": tokens.RefreshToken,
+	}
+}
+
+// renderTFVars renders the variables as HCL, or as JSON when the path ends in .json
+func renderTFVars(path string, tokens *SpotifyTokenResponse) []byte {
+	vars := terraformVariables(tokens)
+
+	if strings.HasSuffix(path, ".json") {
+		data, _ := json.MarshalIndent(vars, "", "  ")
+		return append(data, '\n')
+	}
+
+	var b strings.Builder
+	for _, name := range sortedKeys(vars) {
+		// Go and HCL share the escaping rules for simple quoted strings
+		fmt.Fprintf(&b, "%s = %s\n", name, strconv.Quote(vars[name]))
+	}
+	return []byte(b.String())
+}
+
+// renderEnvFile renders a dotenv file with both the auth proxy's and
+// Terraform's variable names
+func renderEnvFile(tokens *SpotifyTokenResponse) []byte {
+	env := map[string]string{
+		"SPOTIFY_CLIENT_ID":     clientID,
+		"SPOTIFY_CLIENT_SECRET": clientSecret,
+		"SPOTIFY_REDIRECT_URI":  redirectURI,
+		"SPOTIFY_REFRESH_TOKEN": tokens.RefreshToken,
+	}
+	for name, value := range terraformVariables(tokens) {
+		env["TF_VAR_"+name] = value
+	}
+
+	var b strings.Builder
+	for _, name := range sortedKeys(env) {
+		fmt.Fprintf(&b, "%s=%s\n", name, strconv.Quote(env[name]))
+	}
+	return []byte(b.String())
+}
+
+// writeTokenFile writes the token in the format read by the provider's token_file argument
+func writeTokenFile(path string, tokens *SpotifyTokenResponse) error {
+	store := credentials.NewFileStore(path)
+	unlock, err := store.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return store.Save(oauth2Token(tokens, time.Now()))
+}
+
+// oauth2Token converts a token response obtained at issuedAt to an oauth2.Token
+func oauth2Token(tokens *SpotifyTokenResponse, issuedAt time.Time) *oauth2.Token {
+	token := credentials.Token{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    tokens.TokenType,
+		Scope:        tokens.Scope,
+		ExpiresIn:    tokens.ExpiresIn,
+		RefreshToken: tokens.RefreshToken,
+	}
+	return token.OAuth2(issuedAt)
+}
+
+// writePrivateFile writes data to path with 0600 permissions, replacing any existing file
+func writePrivateFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".spotify-auth-*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error setting permissions on %s: %w", path, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// sortedKeys returns the keys of m in a stable order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
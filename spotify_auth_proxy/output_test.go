@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ashrafxbilal/terraform-provider-spotify/spotify/credentials"
+)
+
+func testTokens(t *testing.T) *SpotifyTokenResponse {
+	t.Helper()
+
+	clientID, clientSecret, redirectURI = "test-client-id", "test-client-secret", "http://127.0.0.1:8080/callback"
+	t.Cleanup(func() { clientID, clientSecret, redirectURI = "", "", "" })
+
+	return &SpotifyTokenResponse{
+		AccessToken:  "test-access-token",
+		TokenType:    "Bearer",
+		Scope:        "user-read-private",
+		ExpiresIn:    3600,
+		RefreshToken: "test-refresh-token",
+	}
+}
+
+func TestWriteOutputsTextOmitsClientSecret(t *testing.T) {
+	tokens := testTokens(t)
+
+	var stdout bytes.Buffer
+	if err := writeOutputs(&stdout, outputOptions{Format: formatText}, tokens); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if strings.Contains(stdout.String(), "test-client-secret") {
+		t.Error("Expected the client secret not to be printed")
+	}
+	if !strings.Contains(stdout.String(), `TF_VAR_spotify_refresh_token="test-refresh-token"`) {
+		t.Errorf("Expected the refresh token export line, got %q", stdout.String())
+	}
+}
+
+func TestWriteOutputsJSON(t *testing.T) {
+	tokens := testTokens(t)
+
+	var stdout bytes.Buffer
+	if err := writeOutputs(&stdout, outputOptions{Format: formatJSON}, tokens); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if result["refresh_token"] != "test-refresh-token" || result["client_id"] != "test-client-id" {
+		t.Errorf("Unexpected JSON output: %v", result)
+	}
+	if _, ok := result["client_secret"]; ok {
+		t.Error("Expected the client secret to be omitted from JSON output")
+	}
+}
+
+func TestWriteOutputsFiles(t *testing.T) {
+	tokens := testTokens(t)
+	dir := t.TempDir()
+
+	opts := outputOptions{
+		Format:        formatJSON,
+		TFVarsPath:    filepath.Join(dir, "terraform.tfvars"),
+		EnvFilePath:   filepath.Join(dir, ".env"),
+		TokenFilePath: filepath.Join(dir, "token.json"),
+	}
+	if err := writeOutputs(&bytes.Buffer{}, opts, tokens); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, path := range []string{opts.TFVarsPath, opts.EnvFilePath, opts.TokenFilePath} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Expected %s to be written, got %v", path, err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("Expected %s to have mode 0600, got %v", path, info.Mode().Perm())
+		}
+	}
+
+	tfvars, _ := os.ReadFile(opts.TFVarsPath)
+	if !strings.Contains(string(tfvars), `spotify_client_secret = "test-client-secret"`) {
+		t.Errorf("Unexpected tfvars content: %q", tfvars)
+	}
+
+	env, _ := os.ReadFile(opts.EnvFilePath)
+	for _, line := range []string{`SPOTIFY_REFRESH_TOKEN="test-refresh-token"`, `TF_VAR_spotify_client_id="test-client-id"`} {
+		if !strings.Contains(string(env), line) {
+			t.Errorf("Expected env file to contain %s, got %q", line, env)
+		}
+	}
+
+	token, err := credentials.NewFileStore(opts.TokenFilePath).Load()
+	if err != nil {
+		t.Fatalf("Expected the token file to load, got %v", err)
+	}
+	if token.RefreshToken != "test-refresh-token" || token.AccessToken != "test-access-token" {
+		t.Errorf("Unexpected token: %+v", token)
+	}
+}
+
+func TestRenderTFVarsJSON(t *testing.T) {
+	tokens := testTokens(t)
+
+	var vars map[string]string
+	if err := json.Unmarshal(renderTFVars("spotify.auto.tfvars.json", tokens), &vars); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if vars["spotify_refresh_token"] != "test-refresh-token" {
+		t.Errorf("Unexpected tfvars: %v", vars)
+	}
+}
@@ -2,19 +2,33 @@
 // for use with the Terraform Spotify provider.
 //
 // Usage:
-//   1. Set required environment variables:
-//      - SPOTIFY_CLIENT_ID: Your Spotify application client ID
-//      - SPOTIFY_CLIENT_SECRET: Your Spotify application client secret
-//      - SPOTIFY_REDIRECT_URI: Your registered redirect URI
-//      - SPOTIFY_SCOPES: (Optional) Space-separated list of required scopes
-//   2. Run the program and follow the prompts
-//   3. Use the obtained refresh token in your Terraform configuration
+//  1. Set required environment variables:
+//     - SPOTIFY_CLIENT_ID: Your Spotify application client ID
+//     - SPOTIFY_CLIENT_SECRET: Your Spotify application client secret
+//     - SPOTIFY_REDIRECT_URI: Your registered redirect URI
+//     - SPOTIFY_SCOPES: (Optional) Space-separated list of required scopes
+//  2. Run the program (go run .) and authorize the app in the browser
+//  3. Use the obtained refresh token in your Terraform configuration
+//
+// When the redirect URI points at this machine (for example
+// http://127.0.0.1:8080/callback), the program starts a local server on that
+// address and captures the authorization code automatically. Otherwise, or
+// with -manual, it asks for the code to be pasted instead.
+//
+// Besides login (the default), the following subcommands help debug existing
+// credentials without running Terraform:
+//   - refresh: exchange a refresh token and print the new access token
+//   - inspect: show the user, product and granted scopes of a refresh token
+//   - doctor: check the environment, redirect URI, clock skew and refresh token
 package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -22,6 +36,9 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
+
+	spotifyscopes "github.com/ashrafxbilal/terraform-provider-spotify/spotify/scopes"
 )
 
 // Configuration constants
@@ -31,8 +48,24 @@ var (
 	clientSecret = os.Getenv("SPOTIFY_CLIENT_SECRET")
 	redirectURI  = os.Getenv("SPOTIFY_REDIRECT_URI")
 	scopes       = os.Getenv("SPOTIFY_SCOPES")
+
+	// Base URLs of the Spotify services, overridable for proxies and tests
+	accountsBaseURL = envOrDefault("SPOTIFY_ACCOUNTS_BASE_URL", "https://accounts.spotify.com")
+	apiBaseURL      = envOrDefault("SPOTIFY_API_BASE_URL", "https://api.spotify.com")
 )
 
+// errInvalidGrant is returned when Spotify rejects a code or refresh token,
+// for example because the refresh token was revoked
+var errInvalidGrant = errors.New("invalid_grant")
+
+// envOrDefault returns the value of an environment variable, or def when it is unset
+func envOrDefault(name, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return strings.TrimSuffix(value, "/")
+	}
+	return def
+}
+
 // validateEnvironment checks if all required environment variables are set
 func validateEnvironment() error {
 	missing := []string{}
@@ -48,8 +81,8 @@ func validateEnvironment() error {
 	}
 	if scopes == "" {
 		// Set default scopes if not provided
-		scopes = "ugc-image-upload user-top-read user-read-recently-played user-read-private playlist-modify-public playlist-modify-private"
-		fmt.Println("Warning: SPOTIFY_SCOPES not set, using default scopes")
+		scopes = strings.Join(spotifyscopes.Default, " ")
+		fmt.Fprintln(os.Stderr, "Warning: SPOTIFY_SCOPES not set, using default scopes")
 	}
 
 	if len(missing) > 0 {
@@ -60,57 +93,126 @@ func validateEnvironment() error {
 }
 
 func main() {
+	log.SetFlags(0)
+
+	name, args := commandLogin, os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		printUsage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(args); err != nil {
+		log.Fatalf("%s failed: %v", name, err)
+	}
+}
+
+// runLogin authorizes the app in the browser and exchanges the code for tokens
+func runLogin(args []string) error {
+	flags := flag.NewFlagSet(commandLogin, flag.ExitOnError)
+	manual := flags.Bool("manual", false, "paste the authorization code instead of capturing it with a local callback server")
+	noBrowser := flags.Bool("no-browser", false, "do not open the authorization URL in a browser")
+	timeout := flags.Duration("timeout", 5*time.Minute, "how long to wait for the authorization callback")
+
+	var output outputOptions
+	flags.StringVar(&output.Format, "format", formatText, "stdout format: text or json")
+	flags.StringVar(&output.TFVarsPath, "tfvars", "", "write Terraform variables to this file (HCL, or JSON if it ends in .json)")
+	flags.StringVar(&output.EnvFilePath, "env-file", "", "write a dotenv file with the credentials to this path")
+	flags.StringVar(&output.TokenFilePath, "token-file", "", "write a token file for the provider's token_file argument to this path")
+	_ = flags.Parse(args)
+
+	if err := validateFormat(output.Format); err != nil {
+		return err
+	}
+
 	// Validate environment variables
 	if err := validateEnvironment(); err != nil {
-		log.Fatalf("Environment validation failed: %v\n\nPlease set the required environment variables:\n\nexport SPOTIFY_CLIENT_ID=your_client_id\nexport SPOTIFY_CLIENT_SECRET=your_client_secret\nexport SPOTIFY_REDIRECT_URI=your_redirect_uri\nexport SPOTIFY_SCOPES=your_scopes\n", err)
+		return fmt.Errorf("environment validation failed: %v\n\nPlease set the required environment variables:\n\nexport SPOTIFY_CLIENT_ID=your_client_id\nexport SPOTIFY_CLIENT_SECRET=your_client_secret\nexport SPOTIFY_REDIRECT_URI=your_redirect_uri\nexport SPOTIFY_SCOPES=your_scopes", err)
+	}
+
+	state, err := newState()
+	if err != nil {
+		return err
 	}
 
 	authURL := fmt.Sprintf(
-		"https://accounts.spotify.com/authorize?client_id=%s&response_type=code&redirect_uri=%s&scope=%s",
+		"%s/authorize?client_id=%s&response_type=code&redirect_uri=%s&scope=%s&state=%s",
+		accountsBaseURL,
 		url.QueryEscape(clientID),
 		url.QueryEscape(redirectURI),
 		url.QueryEscape(scopes),
+		url.QueryEscape(state),
 	)
 
-	fmt.Println("1. Open the following URL in your browser and authorize the app:")
-	fmt.Println(authURL)
-	fmt.Println("\n2. After authorization, you'll be redirected to the Glitch page.")
-	fmt.Println("   Copy the 'code' value from the URL in the address bar and paste it below.")
-	fmt.Print("\nPaste code here: ")
-
-	scanner := bufio.NewScanner(os.Stdin)
-	if !scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			log.Fatalf("Error reading input: %v", err)
-		}
-		log.Fatalf("No input provided")
+	var code string
+	if !*manual && isLoopbackRedirect(redirectURI) {
+		code, err = loginWithCallback(authURL, state, *timeout, *noBrowser)
+	} else {
+		code, err = loginWithPaste(authURL, state)
 	}
-	code := scanner.Text()
-
-	if code == "" {
-		log.Fatalf("Authorization code cannot be empty")
+	if err != nil {
+		return fmt.Errorf("authorization failed: %w", err)
 	}
 
 	tokens, err := exchangeCodeForToken(code)
 	if err != nil {
-		log.Fatalf("Failed to exchange code for token: %v", err)
+		return fmt.Errorf("failed to exchange code for token: %w", err)
+	}
+
+	if err := writeOutputs(os.Stdout, output, tokens); err != nil {
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+
+	return nil
+}
+
+// loginWithCallback opens the authorization URL and captures the code with a
+// local server listening on the redirect URI
+func loginWithCallback(authURL, state string, timeout time.Duration, noBrowser bool) (string, error) {
+	fmt.Fprintln(os.Stderr, "1. Authorize the app in your browser. If it does not open, visit:")
+	fmt.Fprintln(os.Stderr, authURL)
+	fmt.Fprintf(os.Stderr, "\n2. Waiting up to %s for Spotify to redirect to %s ...\n", timeout, redirectURI)
+
+	if !noBrowser {
+		if err := openBrowser(authURL); err != nil {
+			fmt.Fprintf(os.Stderr, "   Could not open a browser (%v), please open the URL manually.\n", err)
+		}
+	}
+
+	return waitForCallback(context.Background(), redirectURI, state, timeout)
+}
+
+// loginWithPaste asks the user to paste the code from the redirect URL, for
+// headless machines and redirect URIs that are not on this machine
+func loginWithPaste(authURL, state string) (string, error) {
+	fmt.Fprintln(os.Stderr, "1. Open the following URL in your browser and authorize the app:")
+	fmt.Fprintln(os.Stderr, authURL)
+	fmt.Fprintln(os.Stderr, "\n2. After authorization, you'll be redirected to your redirect URI.")
+	fmt.Fprintln(os.Stderr, "   Paste the full URL from the address bar, or just its 'code' value, below.")
+	fmt.Fprint(os.Stderr, "\nPaste code or URL here: ")
+
+	scanner := bufio.NewScanner(os.Stdin)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return "", fmt.Errorf("error reading input: %w", err)
+		}
+		return "", fmt.Errorf("no input provided")
 	}
 
-	fmt.Println("\nAccess Token:", tokens.AccessToken)
-	fmt.Println("Refresh Token:", tokens.RefreshToken)
-	fmt.Println("\nTo use these tokens with Terraform, set the following environment variables:")
-	fmt.Println("export TF_VAR_spotify_client_id=\"" + clientID + "\"")
-	fmt.Println("export TF_VAR_spotify_client_secret=\"" + clientSecret + "\"")
-	fmt.Println("export TF_VAR_spotify_redirect_uri=\"" + redirectURI + "\"")
-	fmt.Println("export TF_VAR_spotify_refresh_token=\"" + tokens.RefreshToken + "\"")
+	return readPastedCode(scanner.Text(), state)
 }
 
 type SpotifyTokenResponse struct {
-    AccessToken  string `json:"access_token"`
-    TokenType    string `json:"token_type"`
-    Scope        string `json:"scope"`
-    ExpiresIn    int    `json:"expires_in"`
-    RefreshToken string `json:"refresh_token"`
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	Scope        string `json:"scope"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 // ErrorResponse represents an error response from the Spotify API
@@ -120,54 +222,78 @@ type ErrorResponse struct {
 }
 
 func exchangeCodeForToken(code string) (*SpotifyTokenResponse, error) {
-    data := url.Values{}
-    data.Set("grant_type", "authorization_code")
-    data.Set("code", code)
-    data.Set("redirect_uri", redirectURI)
-
-    req, err := http.NewRequest("POST", "https://accounts.spotify.com/api/token", strings.NewReader(data.Encode()))
-    if err != nil {
-        return nil, fmt.Errorf("failed to create request: %w", err)
-    }
-
-    authHeader := base64.StdEncoding.EncodeToString([]byte(clientID + ":" + clientSecret))
-    req.Header.Set("Authorization", "Basic "+authHeader)
-    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-    resp, err := http.DefaultClient.Do(req)
-    if err != nil {
-        return nil, fmt.Errorf("failed to send request: %w", err)
-    }
-    defer resp.Body.Close()
-
-    // Read the response body
-    body, err := io.ReadAll(resp.Body)
-    if err != nil {
-        return nil, fmt.Errorf("failed to read response body: %w", err)
-    }
-
-    // Check for error response
-    if resp.StatusCode != http.StatusOK {
-        var errorResp ErrorResponse
-        if err := json.Unmarshal(body, &errorResp); err == nil && errorResp.Error != "" {
-            return nil, fmt.Errorf("API error: %s - %s", errorResp.Error, errorResp.Description)
-        }
-        return nil, fmt.Errorf("API request failed with status code %d: %s", resp.StatusCode, string(body))
-    }
-
-    // Parse the successful response
-    var tokenData SpotifyTokenResponse
-    if err := json.Unmarshal(body, &tokenData); err != nil {
-        return nil, fmt.Errorf("failed to parse response: %w", err)
-    }
-
-    // Validate the response
-    if tokenData.AccessToken == "" {
-        return nil, fmt.Errorf("received empty access token")
-    }
-    if tokenData.RefreshToken == "" {
-        return nil, fmt.Errorf("received empty refresh token")
-    }
-
-    return &tokenData, nil
+	data := url.Values{}
+	data.Set("grant_type", "authorization_code")
+	data.Set("code", code)
+	data.Set("redirect_uri", redirectURI)
+
+	tokenData, err := requestToken(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenData.RefreshToken == "" {
+		return nil, fmt.Errorf("received empty refresh token")
+	}
+
+	return tokenData, nil
+}
+
+// refreshAccessToken exchanges a refresh token for a new access token. The
+// response only contains a refresh token when Spotify rotated it.
+func refreshAccessToken(refreshToken string) (*SpotifyTokenResponse, error) {
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", refreshToken)
+
+	return requestToken(data)
+}
+
+// requestToken sends a request to the Spotify token endpoint
+func requestToken(data url.Values) (*SpotifyTokenResponse, error) {
+	req, err := http.NewRequest("POST", accountsBaseURL+"/api/token", strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	authHeader := base64.StdEncoding.EncodeToString([]byte(clientID + ":" + clientSecret))
+	req.Header.Set("Authorization", "Basic "+authHeader)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read the response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Check for error response
+	if resp.StatusCode != http.StatusOK {
+		var errorResp ErrorResponse
+		if err := json.Unmarshal(body, &errorResp); err == nil && errorResp.Error != "" {
+			if errorResp.Error == errInvalidGrant.Error() {
+				return nil, fmt.Errorf("API error: %w - %s", errInvalidGrant, errorResp.Description)
+			}
+			return nil, fmt.Errorf("API error: %s - %s", errorResp.Error, errorResp.Description)
+		}
+		return nil, fmt.Errorf("API request failed with status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	// Parse the successful response
+	var tokenData SpotifyTokenResponse
+	if err := json.Unmarshal(body, &tokenData); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Validate the response
+	if tokenData.AccessToken == "" {
+		return nil, fmt.Errorf("received empty access token")
+	}
+
+	return &tokenData, nil
 }